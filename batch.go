@@ -0,0 +1,129 @@
+package benjamin
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// Batch stages images for the buttons and displays of a Surface, so they are
+// sent together on Commit. This avoids the keys of a page changing one by one.
+type Batch struct {
+	surface Surface
+	staged  []Staged
+}
+
+// Staged image for a Drawable.
+type Staged struct {
+	Drawable Drawable
+	Image    image.Image
+}
+
+// BatchCommitter is a Surface that can commit staged images itself, for
+// example to encode all images before sending them.
+type BatchCommitter interface {
+	// CommitBatch sends the staged images, it returns a *BatchError if any of
+	// the drawables failed to update.
+	CommitBatch([]Staged) error
+}
+
+// NewBatch starts a batch of updates for surface.
+func NewBatch(surface Surface) *Batch {
+	return &Batch{surface: surface}
+}
+
+// Set stages image i for drawable d, replacing an image staged earlier for d.
+func (b *Batch) Set(d Drawable, i image.Image) {
+	for n, s := range b.staged {
+		if s.Drawable == d {
+			b.staged[n].Image = i
+			return
+		}
+	}
+	b.staged = append(b.staged, Staged{Drawable: d, Image: i})
+}
+
+// Len is the number of staged images.
+func (b *Batch) Len() int {
+	return len(b.staged)
+}
+
+// Reset discards all staged images.
+func (b *Batch) Reset() {
+	b.staged = b.staged[:0]
+}
+
+// Commit sends all staged images and resets the batch. If the surface is not a
+// BatchCommitter, the images are set one by one. If any of the drawables failed
+// to update, a *BatchError is returned.
+func (b *Batch) Commit() error {
+	defer b.Reset()
+	if c, ok := b.surface.(BatchCommitter); ok {
+		return c.CommitBatch(b.staged)
+	}
+
+	failed := new(BatchError)
+	for _, s := range b.staged {
+		if err := s.Drawable.SetImage(s.Image); err != nil {
+			failed.Add(s.Drawable, err)
+		}
+	}
+	return failed.Err()
+}
+
+// BatchError lists the drawables that failed to update in a batch commit.
+type BatchError struct {
+	Drawables []Drawable
+	Errors    []error
+}
+
+// Add a drawable that failed to update.
+func (err *BatchError) Add(d Drawable, e error) {
+	err.Drawables = append(err.Drawables, d)
+	err.Errors = append(err.Errors, e)
+}
+
+// Err returns err if any drawables failed, or nil otherwise.
+func (err *BatchError) Err() error {
+	if len(err.Errors) == 0 {
+		return nil
+	}
+	return err
+}
+
+func (err *BatchError) Error() string {
+	s := make([]string, len(err.Errors))
+	for i, e := range err.Errors {
+		s[i] = fmt.Sprintf("%s: %v", describeDrawable(err.Drawables[i]), e)
+	}
+	return "benjamin: batch commit failed: " + strings.Join(s, "; ")
+}
+
+// Unwrap returns the error of the first drawable that failed to update.
+func (err *BatchError) Unwrap() error {
+	return err.Errors[0]
+}
+
+func describeDrawable(d Drawable) string {
+	p, ok := d.(Peripheral)
+	if !ok || p.Surface() == nil {
+		return fmt.Sprintf("%T", d)
+	}
+	s, i := p.Surface(), p.Index()
+	switch {
+	case i < 0 && sameDrawable(s.ButtonArea(), d):
+		return "button area"
+	case i < 0 && sameDrawable(s.DisplayArea(), d):
+		return "display area"
+	case i >= 0 && i < s.Buttons() && sameDrawable(s.Button(i), d):
+		return fmt.Sprintf("button %d", i)
+	case i >= 0 && i < s.Displays() && sameDrawable(s.Display(i), d):
+		return fmt.Sprintf("display %d", i)
+	default:
+		return fmt.Sprintf("%T %d", d, i)
+	}
+}
+
+func sameDrawable(a, b Drawable) bool {
+	return a != nil && a == b
+}
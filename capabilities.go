@@ -0,0 +1,141 @@
+package benjamin
+
+import (
+	"fmt"
+	"image"
+)
+
+// ImageFormat is the format images are transferred to a peripheral in.
+type ImageFormat int
+
+const (
+	FormatUnknown ImageFormat = iota
+	FormatJPEG
+	FormatBMP
+)
+
+var imageFormatName = map[ImageFormat]string{
+	FormatUnknown: "unknown",
+	FormatJPEG:    "JPEG",
+	FormatBMP:     "BMP",
+}
+
+func (f ImageFormat) String() string {
+	if s, ok := imageFormatName[f]; ok {
+		return s
+	}
+	return "invalid"
+}
+
+// PeripheralCapabilities describes what a peripheral can do.
+type PeripheralCapabilities struct {
+	// Screen is set if the peripheral can display images.
+	Screen bool
+
+	// Size is the native image size in pixels.
+	Size image.Point
+
+	// Format of the images sent to the peripheral.
+	Format ImageFormat
+
+	// Touch is set if the peripheral is touch sensitive.
+	Touch bool
+
+	// Push is set if the peripheral can be pushed.
+	Push bool
+}
+
+func (c PeripheralCapabilities) String() string {
+	var s string
+	if c.Screen {
+		s = fmt.Sprintf("screen %dx%d %s", c.Size.X, c.Size.Y, c.Format)
+	} else {
+		s = "no screen"
+	}
+	if c.Touch {
+		s += ", touch"
+	}
+	if c.Push {
+		s += ", push"
+	}
+	return s
+}
+
+// BrightnessRange is the supported brightness range.
+type BrightnessRange struct {
+	Min, Max float64
+
+	// Steps is the number of distinct brightness levels, zero if the
+	// brightness can not be changed.
+	Steps int
+}
+
+// Supported returns whether the brightness can be changed.
+func (r BrightnessRange) Supported() bool {
+	return r.Steps > 0
+}
+
+// Capabilities describes what a Surface and its peripherals can do.
+type Capabilities struct {
+	Buttons    []PeripheralCapabilities
+	Displays   []PeripheralCapabilities
+	Encoders   []PeripheralCapabilities
+	Brightness BrightnessRange
+
+	// StandbyImage is set if the image shown while the device is idle (or
+	// the boot logo) can be changed. None of the drivers can set it yet.
+	StandbyImage bool
+}
+
+// CapabilityReporter is a Surface that can describe its capabilities.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// PeripheralCapabilityReporter is a Peripheral that can describe its
+// capabilities.
+type PeripheralCapabilityReporter interface {
+	Capabilities() PeripheralCapabilities
+}
+
+// CapabilitiesOf returns the capabilities of a Surface. If the Surface does not
+// implement CapabilityReporter, the capabilities are derived from its
+// peripherals.
+func CapabilitiesOf(s Surface) Capabilities {
+	if r, ok := s.(CapabilityReporter); ok {
+		return r.Capabilities()
+	}
+
+	var c Capabilities
+	for i, l := 0, s.Buttons(); i < l; i++ {
+		c.Buttons = append(c.Buttons, PeripheralCapabilitiesOf(s.Button(i)))
+	}
+	for i, l := 0, s.Displays(); i < l; i++ {
+		c.Displays = append(c.Displays, PeripheralCapabilitiesOf(s.Display(i)))
+	}
+	for i, l := 0, s.Encoders(); i < l; i++ {
+		c.Encoders = append(c.Encoders, PeripheralCapabilitiesOf(s.Encoder(i)))
+	}
+	c.Brightness = BrightnessRange{Min: 0, Max: 1, Steps: 101}
+	return c
+}
+
+// PeripheralCapabilitiesOf returns the capabilities of a Peripheral. If the
+// Peripheral does not implement PeripheralCapabilityReporter, the capabilities
+// are derived from its type.
+func PeripheralCapabilitiesOf(p Peripheral) PeripheralCapabilities {
+	if r, ok := p.(PeripheralCapabilityReporter); ok {
+		return r.Capabilities()
+	}
+
+	var c PeripheralCapabilities
+	if d, ok := p.(Drawable); ok && HasScreen(d) {
+		c.Screen = true
+		c.Size = d.Size()
+	}
+	switch p.(type) {
+	case Button, Encoder:
+		c.Push = true
+	}
+	return c
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 
 	"github.com/tehmaze/benjamin"
@@ -9,6 +11,19 @@ import (
 )
 
 func main() {
+	watch := flag.Bool("watch", false, "watch for devices being attached or detached")
+	flag.Parse()
+
+	if *watch {
+		for event := range driver.Watch(context.Background()) {
+			fmt.Println(event.Type, event.ID)
+			if event.Type == driver.Attach {
+				describe(event.ID.Key(), event.Device)
+			}
+		}
+		return
+	}
+
 	devices := driver.Scan()
 	if len(devices) == 0 {
 		fmt.Println("no compatible devices found")
@@ -17,15 +32,77 @@ func main() {
 
 	fmt.Println(len(devices), "compatible devices found:")
 	for i, device := range devices {
-		if usb, ok := device.(benjamin.USBDevice); ok {
-			info := usb.DeviceInfo()
-			fmt.Printf("device %d (usb id %04x:%04x)\n", i+1, info.VendorID, info.ProductID)
-			fmt.Println("  +- path:        ", info.Path)
-		} else {
-			fmt.Println("device", i+1)
-		}
-		fmt.Println("  +- manufacturer:", device.Manufacturer())
-		fmt.Println("  +- product:     ", device.Product())
+		describe(fmt.Sprint(i+1), device)
+	}
+}
+
+func describe(name string, device benjamin.Device) {
+	if usb, ok := device.(benjamin.USBDevice); ok {
+		info := usb.DeviceInfo()
+		fmt.Printf("device %s (usb id %04x:%04x)\n", name, info.VendorID, info.ProductID)
+		fmt.Println("  +- path:        ", info.Path)
+	} else {
+		fmt.Println("device", name)
+	}
+	fmt.Println("  +- manufacturer:", device.Manufacturer())
+	fmt.Println("  +- product:     ", device.Product())
+	describeCapabilities(benjamin.CapabilitiesOf(device))
+	if fw, ok := device.(benjamin.FirmwareDevice); ok {
+		fmt.Println("  +- serial:      ", device.Serial())
+		describeFirmware(device, fw)
+	} else {
 		fmt.Println("  `- serial:      ", device.Serial())
 	}
 }
+
+func describeFirmware(device benjamin.Device, fw benjamin.FirmwareDevice) {
+	if err := device.Open(); err != nil {
+		fmt.Println("  `- firmware:     error:", err)
+		return
+	}
+	defer device.Close()
+
+	if serial, err := fw.DeviceSerial(); err != nil {
+		fmt.Println("  +- dev serial:   error:", err)
+	} else {
+		fmt.Println("  +- dev serial:  ", serial)
+	}
+	if version, err := fw.Firmware(); err != nil {
+		fmt.Println("  `- firmware:     error:", err)
+	} else {
+		fmt.Println("  `- firmware:    ", version)
+	}
+}
+
+func describeCapabilities(c benjamin.Capabilities) {
+	describePeripherals("buttons:     ", c.Buttons)
+	describePeripherals("displays:    ", c.Displays)
+	describePeripherals("encoders:    ", c.Encoders)
+	if c.Brightness.Supported() {
+		fmt.Printf("  +- brightness:   %g-%g (%d steps)\n", c.Brightness.Min, c.Brightness.Max, c.Brightness.Steps)
+	} else {
+		fmt.Println("  +- brightness:   not supported")
+	}
+	fmt.Println("  +- standby img: ", c.StandbyImage)
+}
+
+// describePeripherals prints the number of peripherals per distinct set of
+// capabilities.
+func describePeripherals(name string, cs []benjamin.PeripheralCapabilities) {
+	if len(cs) == 0 {
+		return
+	}
+	var (
+		order  []benjamin.PeripheralCapabilities
+		counts = make(map[benjamin.PeripheralCapabilities]int)
+	)
+	for _, c := range cs {
+		if counts[c] == 0 {
+			order = append(order, c)
+		}
+		counts[c]++
+	}
+	for _, c := range order {
+		fmt.Printf("  +- %s %d (%s)\n", name, counts[c], c)
+	}
+}
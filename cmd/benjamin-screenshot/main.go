@@ -0,0 +1,123 @@
+// Command benjamin-screenshot saves what a device showed to a PNG image.
+//
+// The images are rendered from the output reports recorded in a traffic
+// capture, as written by benjamin-test with the -capture flag or by
+// applications using transport.CaptureOpener. The device model is taken from
+// the capture.
+//
+// A device can't be asked what it shows and a newly opened device knows no
+// images, so the command does not open devices. Applications that want to save
+// what their devices show can use the Snapshot method of their devices.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"os"
+
+	"github.com/karalabe/hid"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver"
+	"github.com/tehmaze/benjamin/driver/transport"
+
+	_ "github.com/tehmaze/benjamin/driver/all" // All hardware drivers
+)
+
+// outputReplayer is a device that can update its images from recorded output.
+type outputReplayer interface {
+	ReplayOutput([]transport.Record) error
+}
+
+func main() {
+	capture := flag.String("capture", "", "render the images recorded in this capture (required)")
+	path := flag.String("path", "", "path of the device in the capture, defaults to the first device")
+	rotate := flag.Int("rotate", 0, "clockwise mounting rotation in degrees (0, 90, 180 or 270)")
+	output := flag.String("o", "screenshot.png", "output file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s -capture <file> [flags]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 || *capture == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*capture)
+	if err != nil {
+		log.Fatal(err)
+	}
+	i, err := screenshot(f, *path, benjamin.Rotation(*rotate/90))
+	_ = f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	o, err := os.Create(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = png.Encode(o, i); err != nil {
+		_ = o.Close()
+		log.Fatal(err)
+	}
+	if err = o.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("screenshot: wrote %s (%dx%d)", *output, i.Bounds().Dx(), i.Bounds().Dy())
+}
+
+// screenshot renders what the device at path in the capture showed, mounted
+// with rotation.
+func screenshot(r io.Reader, path string, rotation benjamin.Rotation) (image.Image, error) {
+	replay, err := transport.NewReplayOf(r, path)
+	if err != nil {
+		return nil, err
+	}
+	info := replay.Info()
+	if info.VendorID == 0 && info.ProductID == 0 {
+		return nil, errors.New("screenshot: the capture does not record the device, capture it again with benjamin-test -capture")
+	}
+
+	var written bool
+	for _, record := range replay.Output() {
+		written = written || record.Op == transport.OpWrite
+	}
+	if !written {
+		return nil, fmt.Errorf("screenshot: no images were sent to %s in the capture", info.Path)
+	}
+
+	open := func(hid.DeviceInfo) (transport.Transport, error) { return replay, nil }
+	d, err := driver.NewUSBWithOpener(info, open)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.Open(); err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	if r, ok := d.(benjamin.Rotatable); ok {
+		r.SetRotation(rotation)
+	} else if rotation != benjamin.Rotate0 {
+		return nil, fmt.Errorf("screenshot: %s can not be rotated", d.Product())
+	}
+	o, ok := d.(outputReplayer)
+	if !ok {
+		return nil, fmt.Errorf("screenshot: can not render captures of %s", d.Product())
+	}
+	if err = o.ReplayOutput(replay.Output()); err != nil {
+		return nil, err
+	}
+	s, ok := d.(benjamin.Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("screenshot: %s does not support snapshots", d.Product())
+	}
+	return s.Snapshot()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/karalabe/hid"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/streamdeck"
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+// capture records the traffic of a Mini, with red on key 0 if red is set.
+func capture(t *testing.T, red bool) *bytes.Buffer {
+	t.Helper()
+	var (
+		b    = new(bytes.Buffer)
+		info = hid.DeviceInfo{Path: "mini", VendorID: streamdeck.VendorID, ProductID: streamdeck.Mini.ProductID}
+		open = transport.CaptureOpener(func(hid.DeviceInfo) (transport.Transport, error) {
+			return transport.NewMemory(), nil
+		}, b)
+		d = streamdeck.NewWithOpener(info, streamdeck.Mini, open)
+	)
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if red {
+		i := image.NewNRGBA(image.Rectangle{Max: d.Button(0).Size()})
+		draw.Draw(i, i.Rect, image.NewUniform(color.NRGBA{R: 0xff, A: 0xff}), image.Point{}, draw.Src)
+		if err := d.Button(0).SetImage(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestScreenshot(t *testing.T) {
+	i, err := screenshot(capture(t, true), "", benjamin.Rotate0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.NRGBAModel.Convert(i.At(10, 10)); c != (color.NRGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("expected red key 0, got %v", c)
+	}
+}
+
+func TestScreenshotWithoutImages(t *testing.T) {
+	if _, err := screenshot(capture(t, false), "", benjamin.Rotate0); err == nil {
+		t.Error("expected error for a capture without images")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"flag"
 	"image"
 	"image/color"
@@ -14,6 +15,8 @@ import (
 
 	"github.com/tehmaze/benjamin"
 	"github.com/tehmaze/benjamin/driver"
+	"github.com/tehmaze/benjamin/driver/streamdeck"
+	"github.com/tehmaze/benjamin/driver/transport"
 	"github.com/tehmaze/benjamin/widget"
 
 	_ "github.com/tehmaze/benjamin/driver/all" // All hardware drivers
@@ -21,17 +24,51 @@ import (
 
 func main() {
 	serial := flag.String("serial", "", "use device with this serial number")
+	model := flag.String("model", "", "use device of this model")
+	match := flag.String("match", "", "use device with serial, path or model matching this pattern")
 	fps := flag.Int("fps", 25, "maximum frame rate")
 	brightness := flag.Float64("brightness", 60, "brightness percentage")
+	capture := flag.String("capture", "", "capture device traffic to this file")
+	rotate := flag.Int("rotate", 0, "clockwise mounting rotation in degrees (0, 90, 180 or 270)")
+	quality := flag.Int("quality", 0, "JPEG quality (1-100), lower qualities send faster")
 	flag.Parse()
 
-	d, err := newDeck(*serial)
+	open := transport.Open
+	if *capture != "" {
+		f, err := os.Create(*capture)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		open = transport.CaptureOpener(transport.Open, f)
+	}
+
+	var filters []driver.Filter
+	if *serial != "" {
+		filters = append(filters, driver.BySerial(*serial))
+	}
+	if *model != "" {
+		filters = append(filters, driver.ByModel(*model))
+	}
+	if *match != "" {
+		filters = append(filters, driver.ByGlob(*match))
+	}
+
+	d, err := driver.OpenMatchingWith(open, filters...)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	defer d.Close()
-	if err = d.Reset(); err != nil {
+	if r, ok := d.(benjamin.Rotatable); ok {
+		r.SetRotation(benjamin.Rotation(*rotate / 90))
+	} else if *rotate != 0 {
+		log.Println("test: device can not be rotated")
+	}
+	if s, ok := d.(*streamdeck.Device); ok && *quality > 0 {
+		s.SetEncoding(streamdeck.Encoding{Quality: *quality})
+	}
+	if err = d.Reset(); err != nil && !errors.Is(err, benjamin.ErrNotSupported) {
 		log.Fatal(err)
 	}
 
@@ -41,7 +78,7 @@ func main() {
 		*brightness = 100
 	}
 
-	if err = d.SetBrightness(*brightness / 100); err != nil {
+	if err = d.SetBrightness(*brightness / 100); err != nil && !errors.Is(err, benjamin.ErrNotSupported) {
 		log.Fatal(err)
 	}
 
@@ -67,20 +104,6 @@ func main() {
 	}
 }
 
-func newDeck(serial string) (d benjamin.Device, err error) {
-	if serial == "" {
-		return driver.Open()
-	}
-
-	for _, d = range driver.Scan() {
-		if d.Serial() == serial {
-			return d, d.Open()
-		}
-	}
-
-	return nil, driver.ErrNotFound
-}
-
 func addButtons(d benjamin.Device, r benjamin.Router) (widgets []widget.Widget) {
 	dim := d.ButtonLayout()
 
@@ -89,6 +112,12 @@ func addButtons(d benjamin.Device, r benjamin.Router) (widgets []widget.Widget)
 		for x := 0; x < dim.X; x++ {
 			// log.Printf("test: key (%d,%d)", x, y)
 			k := d.ButtonAt(image.Pt(x, y))
+			if !benjamin.HasScreen(k) {
+				r.On(k, benjamin.TypeButtonPress, benjamin.EventHandlerFunc(func(event benjamin.Event) {
+					log.Printf("test: button %s pressed", event.Peripheral.(benjamin.Button).Position())
+				}))
+				continue
+			}
 			if x == 0 && y == 0 {
 				w := widget.ButtonIcon(k, door)
 				r.On(k, benjamin.TypeButtonPress, benjamin.EventHandlerFunc(func(_ benjamin.Event) {
@@ -14,5 +14,7 @@
 //	Elgato Stream Deck XL
 //	Elgato Stream Deck XL V2
 //	Elgato Stream Deck +
+//	Elgato Stream Deck Neo
+//	Elgato Stream Deck Pedal
 //	Inifinitton iButton
 package benjamin
@@ -3,76 +3,177 @@ package driver
 import (
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/karalabe/hid"
 
 	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/transport"
 )
 
 // USBDriver returns a device driver for a USB device.
 type USBDriver func(hid.DeviceInfo) benjamin.Device
 
+// USBOpenerDriver returns a device driver for a USB device, that opens the
+// device with the supplied Opener.
+type USBOpenerDriver func(hid.DeviceInfo, transport.Opener) benjamin.Device
+
 // Driver returns a device drivers for a device.
 type Driver func() benjamin.Device
 
 type deviceDriver struct {
 	Detect func() bool
 	Driver Driver
+
+	// The device constructed when the driver detected a device, it is kept
+	// while the device is detected, so it is not constructed on every scan.
+	mu     sync.Mutex
+	last   benjamin.Device
+	handed bool // last was returned by a candidate
+}
+
+// detected returns the candidate for a detected device.
+func (driver *deviceDriver) detected() candidate {
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	if driver.last == nil {
+		driver.last, driver.handed = driver.Driver(), false
+	}
+	return candidate{
+		id:     IDOf(driver.last),
+		device: driver.device,
+	}
+}
+
+// device returns the last constructed device, or a new device if that one was
+// returned before. Registered drivers open their devices themselves, so the
+// opener is not used.
+func (driver *deviceDriver) device(transport.Opener) benjamin.Device {
+	driver.mu.Lock()
+	defer driver.mu.Unlock()
+	if driver.last == nil || driver.handed {
+		return driver.Driver()
+	}
+	driver.handed = true
+	return driver.last
+}
+
+// lost forgets the constructed device, the driver no longer detects it.
+func (driver *deviceDriver) lost() {
+	driver.mu.Lock()
+	driver.last = nil
+	driver.mu.Unlock()
 }
 
 var (
 	ErrNotFound = errors.New("benjamin: no compatible device found")
-	usbDrivers  = make(map[uint16]map[uint16]USBDriver)
-	drivers     []deviceDriver
+	usbDrivers  = make(map[uint16]map[uint16]USBOpenerDriver)
+	drivers     []*deviceDriver
+	driversMu   sync.Mutex // guards drivers, Watch scans in the background
 )
 
 // Register a driver.
 func Register(detect func() bool, driver Driver) {
-	drivers = append(drivers, deviceDriver{
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	drivers = append(drivers, &deviceDriver{
 		Detect: detect,
 		Driver: driver,
 	})
 }
 
-// RegisterUSB registers a USB driver.
+// RegisterUSB registers a USB driver. The driver opens devices its own way,
+// usually with transport.Open, so it ignores the Opener passed to the With
+// variants of the functions in this package.
 func RegisterUSB(vendorID, productID uint16, driver USBDriver) {
+	RegisterUSBWithOpener(vendorID, productID, func(info hid.DeviceInfo, _ transport.Opener) benjamin.Device {
+		return driver(info)
+	})
+}
+
+// RegisterUSBWithOpener registers a USB driver that opens devices with the
+// supplied Opener.
+func RegisterUSBWithOpener(vendorID, productID uint16, driver USBOpenerDriver) {
 	if _, dupe := usbDrivers[vendorID][productID]; dupe {
 		panic(fmt.Sprintf("USB driver for %04x:%04x already registered", vendorID, productID))
 	}
 	if _, ok := usbDrivers[vendorID]; !ok {
-		usbDrivers[vendorID] = make(map[uint16]USBDriver)
+		usbDrivers[vendorID] = make(map[uint16]USBOpenerDriver)
 	}
 	usbDrivers[vendorID][productID] = driver
 }
 
+// NewUSB returns a device for the USB device described by info, using the driver
+// registered for it.
+func NewUSB(info hid.DeviceInfo) (benjamin.Device, error) {
+	return NewUSBWithOpener(info, transport.Open)
+}
+
+// NewUSBWithOpener is like NewUSB, but the device is opened with open.
+func NewUSBWithOpener(info hid.DeviceInfo, open transport.Opener) (benjamin.Device, error) {
+	d, ok := usbDrivers[info.VendorID][info.ProductID]
+	if !ok {
+		return nil, fmt.Errorf("benjamin: USB device %04x:%04x: %w", info.VendorID, info.ProductID, ErrNotFound)
+	}
+	return d(info, open), nil
+}
+
 // Scan available devices.
 func Scan() []benjamin.Device {
+	return ScanWith(transport.Open)
+}
+
+// ScanWith is like Scan, but USB devices are opened with the supplied Opener.
+func ScanWith(open transport.Opener) []benjamin.Device {
 	var available []benjamin.Device
+	for _, candidate := range enumerate() {
+		available = append(available, candidate.device(open))
+	}
+	return available
+}
+
+// candidate is a detected device, the device driver is only instantiated when
+// needed. USB devices are identified by their HID device info, drivers
+// registered with Register are constructed once while they detect a device.
+type candidate struct {
+	id     ID
+	device func(transport.Opener) benjamin.Device
+}
+
+func enumerate() []candidate {
+	var available []candidate
 
 	// Enumerate the USB bus for known drivers.
 	for vendorID, devices := range usbDrivers {
 		for _, info := range hid.Enumerate(vendorID, 0) {
 			if d, ok := devices[info.ProductID]; ok {
-				available = append(available, d(info))
+				info := info
+				available = append(available, candidate{
+					id:     idOf(info),
+					device: func(open transport.Opener) benjamin.Device { return d(info, open) },
+				})
 			}
 		}
 	}
 
 	// Enumerate driver detections.
-	for _, driver := range drivers {
+	driversMu.Lock()
+	registered := drivers
+	driversMu.Unlock()
+	for _, driver := range registered {
 		if driver.Detect() {
-			available = append(available, driver.Driver())
+			available = append(available, driver.detected())
+		} else {
+			driver.lost()
 		}
 	}
 
 	return available
 }
 
-// Open the first available device.
+// Open the first available device that can be opened. If there are no
+// devices, the returned error is a *NoMatchError, not ErrNotFound itself;
+// compare with errors.Is(err, ErrNotFound) instead of ==.
 func Open() (benjamin.Device, error) {
-	for _, device := range Scan() {
-		err := device.Open()
-		return device, err
-	}
-	return nil, ErrNotFound
+	return OpenMatching()
 }
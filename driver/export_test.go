@@ -0,0 +1,32 @@
+package driver
+
+import (
+	"testing"
+	"time"
+)
+
+// RegisterForTest registers a driver until the test ends.
+func RegisterForTest(t testing.TB, detect func() bool, driver Driver) {
+	d := &deviceDriver{Detect: detect, Driver: driver}
+	driversMu.Lock()
+	drivers = append(drivers, d)
+	driversMu.Unlock()
+
+	t.Cleanup(func() {
+		driversMu.Lock()
+		defer driversMu.Unlock()
+		for i, registered := range drivers {
+			if registered == d {
+				drivers = append(drivers[:i:i], drivers[i+1:]...)
+				break
+			}
+		}
+	})
+}
+
+// SetWatchIntervalForTest sets WatchInterval until the test ends.
+func SetWatchIntervalForTest(t testing.TB, interval time.Duration) {
+	previous := WatchInterval
+	WatchInterval = interval
+	t.Cleanup(func() { WatchInterval = previous })
+}
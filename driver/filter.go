@@ -0,0 +1,199 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+// Filter selects devices.
+type Filter func(benjamin.Device) bool
+
+// BySerial selects devices by serial number.
+func BySerial(serial string) Filter {
+	return func(device benjamin.Device) bool {
+		return device.Serial() == serial
+	}
+}
+
+// ByPath selects devices by their platform specific device path.
+func ByPath(path string) Filter {
+	return func(device benjamin.Device) bool {
+		return IDOf(device).Path == path
+	}
+}
+
+// ByUSBID selects USB devices by vendor and product ID, a productID of zero
+// selects all products of the vendor.
+func ByUSBID(vendorID, productID uint16) Filter {
+	return func(device benjamin.Device) bool {
+		usb, ok := device.(benjamin.USBDevice)
+		if !ok {
+			return false
+		}
+		info := usb.DeviceInfo()
+		return info.VendorID == vendorID && (productID == 0 || info.ProductID == productID)
+	}
+}
+
+// ByModel selects devices by model name (case insensitive), for devices that
+// don't report a model, the product name is used.
+func ByModel(model string) Filter {
+	return func(device benjamin.Device) bool {
+		return strings.EqualFold(modelOf(device), model)
+	}
+}
+
+// ByGlob selects devices where the serial, path, model or product name matches
+// the shell pattern, as understood by path.Match.
+func ByGlob(pattern string) Filter {
+	return func(device benjamin.Device) bool {
+		for _, s := range []string{
+			device.Serial(),
+			IDOf(device).Path,
+			modelOf(device),
+			device.Product(),
+		} {
+			if ok, _ := path.Match(pattern, s); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Any selects devices matching any of the filters.
+func Any(filters ...Filter) Filter {
+	return func(device benjamin.Device) bool {
+		for _, filter := range filters {
+			if filter(device) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Match returns the available devices matching all filters.
+func Match(filters ...Filter) []benjamin.Device {
+	var matching []benjamin.Device
+	for _, device := range Scan() {
+		if matches(device, filters) {
+			matching = append(matching, device)
+		}
+	}
+	return matching
+}
+
+func matches(device benjamin.Device, filters []Filter) bool {
+	for _, filter := range filters {
+		if !filter(device) {
+			return false
+		}
+	}
+	return true
+}
+
+// OpenMatching opens the first available device matching all filters. If a
+// matching device can't be opened, the next matching device is tried.
+//
+// If no device matches, a *NoMatchError is returned. If none of the matching
+// devices could be opened, an *OpenError is returned.
+func OpenMatching(filters ...Filter) (benjamin.Device, error) {
+	return OpenMatchingWith(transport.Open, filters...)
+}
+
+// OpenMatchingWith is like OpenMatching, but USB devices are opened with the
+// supplied Opener, for example to capture their traffic with CaptureOpener.
+func OpenMatchingWith(open transport.Opener, filters ...Filter) (benjamin.Device, error) {
+	var (
+		available = ScanWith(open)
+		failed    = new(OpenError)
+	)
+	for _, device := range available {
+		if !matches(device, filters) {
+			continue
+		}
+		if err := device.Open(); err != nil {
+			failed.Devices = append(failed.Devices, device)
+			failed.Errors = append(failed.Errors, err)
+			continue
+		}
+		return device, nil
+	}
+	if len(failed.Errors) > 0 {
+		return nil, failed
+	}
+	return nil, &NoMatchError{Candidates: available}
+}
+
+// NoMatchError is returned if no device matched, it lists the devices that
+// were available. It wraps ErrNotFound, so use errors.Is to test for
+// ErrNotFound.
+type NoMatchError struct {
+	Candidates []benjamin.Device
+}
+
+func (err *NoMatchError) Error() string {
+	if len(err.Candidates) == 0 {
+		return ErrNotFound.Error()
+	}
+	s := make([]string, len(err.Candidates))
+	for i, device := range err.Candidates {
+		s[i] = describe(device)
+	}
+	return "benjamin: no matching device found, available: " + strings.Join(s, ", ")
+}
+
+func (err *NoMatchError) Unwrap() error {
+	return ErrNotFound
+}
+
+// OpenError is returned if none of the matching devices could be opened.
+type OpenError struct {
+	Devices []benjamin.Device
+	Errors  []error
+}
+
+func (err *OpenError) Error() string {
+	s := make([]string, len(err.Errors))
+	for i, e := range err.Errors {
+		s[i] = fmt.Sprintf("%s: %v", describe(err.Devices[i]), e)
+	}
+	return "benjamin: failed to open " + strings.Join(s, "; ")
+}
+
+// Is reports whether any of the devices failed to open with target.
+func (err *OpenError) Is(target error) bool {
+	for _, e := range err.Errors {
+		if errors.Is(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of the devices that matches target.
+func (err *OpenError) As(target any) bool {
+	for _, e := range err.Errors {
+		if errors.As(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func modelOf(device benjamin.Device) string {
+	if m, ok := device.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+	return device.Product()
+}
+
+func describe(device benjamin.Device) string {
+	return fmt.Sprintf("%s (%s)", IDOf(device), modelOf(device))
+}
@@ -0,0 +1,64 @@
+package driver_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver"
+	"github.com/tehmaze/benjamin/driver/mock"
+)
+
+type filterDevice struct {
+	*mock.Mock
+	serial string
+}
+
+func (d filterDevice) Serial() string { return d.serial }
+
+func TestOpenMatching(t *testing.T) {
+	errDenied := errors.New("permission denied")
+	driver.RegisterForTest(t, func() bool { return true }, func() benjamin.Device {
+		m := mock.NewWithLayout(mock.DefaultLayout)
+		m.ErrOpen = errDenied
+		return filterDevice{m, "filter-a"}
+	})
+	driver.RegisterForTest(t, func() bool { return true }, func() benjamin.Device {
+		return filterDevice{mock.NewWithLayout(mock.DefaultLayout), "filter-b"}
+	})
+	errBusy := errors.New("device busy")
+	driver.RegisterForTest(t, func() bool { return true }, func() benjamin.Device {
+		m := mock.NewWithLayout(mock.DefaultLayout)
+		m.ErrOpen = errBusy
+		return filterDevice{m, "filter-d"}
+	})
+
+	d, err := driver.OpenMatching(driver.ByGlob("filter-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := d.Serial(); s != "filter-b" {
+		t.Errorf("expected filter-b to be opened, got %q", s)
+	}
+
+	if _, err = driver.OpenMatching(driver.BySerial("filter-a")); !errors.Is(err, errDenied) {
+		t.Errorf("expected %v, got %v", errDenied, err)
+	}
+
+	// The errors of all devices that failed to open are reported.
+	_, err = driver.OpenMatching(driver.ByGlob("filter-[ad]"))
+	for _, want := range []error{errDenied, errBusy} {
+		if !errors.Is(err, want) {
+			t.Errorf("expected %v in %v", want, err)
+		}
+	}
+
+	_, err = driver.OpenMatching(driver.BySerial("filter-c"), driver.ByModel("mock"))
+	if !errors.Is(err, driver.ErrNotFound) {
+		t.Fatalf("expected %v, got %v", driver.ErrNotFound, err)
+	}
+	var noMatch *driver.NoMatchError
+	if !errors.As(err, &noMatch) || len(noMatch.Candidates) == 0 {
+		t.Errorf("expected candidates in %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package infinitton
 
 import (
 	"image"
+	"sync"
+	"time"
 
 	"github.com/tehmaze/benjamin"
 	"github.com/tehmaze/benjamin/internal/imageutil"
@@ -9,14 +11,25 @@ import (
 )
 
 type button struct {
-	device *iDisplay
-	index  int
+	device  *iDisplay
+	index   int
+	pressed bool
+	press   time.Time
+	mu      sync.Mutex // guards the fields below, held while writing a frame
+	sent    uint64     // sum of the last frame sent
+	valid   bool       // set if sent is known to be shown
+	frame   *imageutil.BGR
+	page    []byte
+	report  []byte
 }
 
 func newButton(d *iDisplay, index int) *button {
 	return &button{
 		index:  index,
 		device: d,
+		frame:  imageutil.NewBGR(image.Rectangle{Max: image.Pt(72, 72)}),
+		page:   make([]byte, pagePacketSize),
+		report: make([]byte, len(reportPixels)),
 	}
 }
 
@@ -32,19 +45,44 @@ func (k *button) Size() image.Point {
 	return k.device.ButtonSize()
 }
 
+func (k *button) Capabilities() benjamin.PeripheralCapabilities {
+	return benjamin.PeripheralCapabilities{
+		Screen: true,
+		Size:   k.Size(),
+		Format: benjamin.FormatBMP,
+		Push:   true,
+	}
+}
+
 func (k *button) Surface() benjamin.Surface {
 	return k.device
 }
 
 func (k *button) SetImage(i image.Image) error {
-	r := image.Rectangle{Max: image.Pt(72, 72)}
-	o := imageutil.NewBGR(r)
-	if i.Bounds().Eq(o.Rect) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	o := k.frame
+	if n, ok := i.(*image.NRGBA); ok && n.Rect.Size().Eq(o.Rect.Size()) {
+		// Fast path, convert pixels.
+		imageutil.ToBGRInto(o, n)
+	} else if i.Bounds().Eq(o.Rect) {
 		draw.Copy(o, image.Point{}, i, i.Bounds(), draw.Src, nil)
 	} else {
 		draw.CatmullRom.Scale(o, o.Rect, i, i.Bounds(), draw.Src, nil)
 	}
-	return k.writePixelData(o.Pix)
+
+	// Skip unchanged frames.
+	sum := imageutil.Sum(o.Pix)
+	if k.valid && k.sent == sum {
+		return nil
+	}
+	if err := k.writePixelData(o.Pix); err != nil {
+		k.valid = false
+		return err
+	}
+	k.sent, k.valid = sum, true
+	return nil
 }
 
 var (
@@ -64,13 +102,19 @@ var (
 		0x00, 0x55, 0xaa, 0xaa, 0x55, 0x11, 0x22, 0x33,
 		0x44,
 	}
+	reportPixels = []byte{
+		0x00, 0x12, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0xf6, 0x3c, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00,
+	}
 )
 
 func (k *button) writePixelData(b []byte) error {
 	var (
-		p1   = b[:7946]
-		p2   = b[7946:]
-		kidx = byte(k.index) + 1
+		p1 = b[:7946]
+		p2 = b[7946:]
 	)
 	if err := k.writePixelDataPage(headerPixelsPage1, p1); err != nil {
 		return err
@@ -79,13 +123,9 @@ func (k *button) writePixelData(b []byte) error {
 		return err
 	}
 
-	r := []byte{
-		0x00, 0x12, 0x01, 0x00, 0x00, kidx, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0xf6, 0x3c, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00,
-	}
+	r := k.report
+	copy(r, reportPixels)
+	r[5] = byte(k.index) + 1
 	if _, err := k.device.dev.SendFeatureReport(r); err != nil {
 		return err
 	}
@@ -95,15 +135,18 @@ func (k *button) writePixelData(b []byte) error {
 const pagePacketSize = 8017
 
 func (k *button) writePixelDataPage(h, p []byte) error {
-	b := make([]byte, pagePacketSize)
+	b := k.page
 
 	// Write header
-	copy(b, h)
+	n := copy(b, h)
 
-	// Write payload
-	copy(b[len(h):], p)
+	// Write payload, pad the remainder
+	n += copy(b[n:], p)
+	for i := n; i < len(b); i++ {
+		b[i] = 0
+	}
 
 	// Send packet
-	_, err := k.device.dev.Write(p)
+	_, err := k.device.dev.Write(b)
 	return err
 }
@@ -1,13 +1,18 @@
 package infinitton
 
 import (
+	"bytes"
+	"encoding/binary"
 	"image"
+	"io"
+	"time"
 
 	"github.com/karalabe/hid"
 	"golang.org/x/image/draw"
 
 	"github.com/tehmaze/benjamin"
 	"github.com/tehmaze/benjamin/driver"
+	"github.com/tehmaze/benjamin/driver/transport"
 	"github.com/tehmaze/benjamin/internal/imageutil"
 )
 
@@ -23,17 +28,37 @@ func New(info hid.DeviceInfo) benjamin.Device {
 
 type iDisplay struct {
 	info         hid.DeviceInfo
-	dev          *hid.Device
+	open         transport.Opener
+	dev          transport.Transport
 	button       [15]*button
-	buttonCanvas *imageutil.BGR
-	canvas       *imageutil.BGR
+	buttonCanvas *image.NRGBA
+	canvas       *image.NRGBA
 }
 
 func NewIDisplay(info hid.DeviceInfo) *iDisplay {
+	return newIDisplay(info, transport.Open)
+}
+
+// NewIDisplayWithOpener is like NewIDisplay, but opens the device with the
+// supplied Opener.
+func NewIDisplayWithOpener(info hid.DeviceInfo, open transport.Opener) *iDisplay {
+	return newIDisplay(info, open)
+}
+
+// NewIDisplayWithTransport is like NewIDisplay, but communicates through the
+// supplied Transport.
+func NewIDisplayWithTransport(info hid.DeviceInfo, t transport.Transport) *iDisplay {
+	d := newIDisplay(info, nil)
+	d.dev = t
+	return d
+}
+
+func newIDisplay(info hid.DeviceInfo, open transport.Opener) *iDisplay {
 	d := &iDisplay{
 		info:         info,
-		buttonCanvas: imageutil.NewBGR(image.Rect(0, 0, 72, 72)),
-		canvas:       imageutil.NewBGR(image.Rect(0, 0, 3*72, 5*72)),
+		open:         open,
+		buttonCanvas: image.NewNRGBA(image.Rect(0, 0, 72, 72)),
+		canvas:       image.NewNRGBA(image.Rect(0, 0, 3*72, 5*72)),
 	}
 	for i := range d.button {
 		d.button[i] = newButton(d, i)
@@ -42,7 +67,12 @@ func NewIDisplay(info hid.DeviceInfo) *iDisplay {
 }
 
 func (d *iDisplay) Open() (err error) {
-	d.dev, err = d.info.Open()
+	if d.dev == nil {
+		if d.open == nil {
+			return io.ErrClosedPipe
+		}
+		d.dev, err = d.open(d.info)
+	}
 	return
 }
 
@@ -50,16 +80,29 @@ func (d *iDisplay) Close() error {
 	return d.dev.Close()
 }
 
+// Reset the device, the iDisplay has no boot logo to return to, so this
+// clears all buttons.
 func (d *iDisplay) Reset() error {
-	return nil // TODO(maze): not implemented
+	d.Invalidate()
+	return d.Clear()
+}
+
+// Invalidate forgets which frames were sent, so the next image set on every
+// button is sent even if it is unchanged.
+func (d *iDisplay) Invalidate() {
+	for _, k := range d.button {
+		k.mu.Lock()
+		k.valid = false
+		k.mu.Unlock()
+	}
 }
 
 func (d *iDisplay) DeviceInfo() hid.DeviceInfo   { return d.info }
 func (d *iDisplay) Path() string                 { return d.info.Path }
 func (d *iDisplay) Manufacturer() string         { return "Infinitton" }
 func (d *iDisplay) Product() string              { return d.info.Product }
+func (d *iDisplay) Model() string                { return "iDisplay" }
 func (d *iDisplay) Serial() string               { return d.info.Serial }
-func (d *iDisplay) Button(int) benjamin.Button   { return nil }
 func (d *iDisplay) Buttons() int                 { return 15 }
 func (d *iDisplay) ButtonLayout() image.Point    { return image.Pt(3, 5) }
 func (d *iDisplay) ButtonSize() image.Point      { return image.Pt(72, 72) }
@@ -69,6 +112,13 @@ func (d *iDisplay) DisplaySize() image.Point     { return image.Point{} }
 func (d *iDisplay) Encoder(int) benjamin.Encoder { return nil }
 func (d *iDisplay) Encoders() int                { return 0 }
 
+func (d *iDisplay) Button(index int) benjamin.Button {
+	if index < 0 || index >= len(d.button) {
+		return nil
+	}
+	return d.button[index]
+}
+
 func (d *iDisplay) ButtonAt(p image.Point) benjamin.Button {
 	if p.X < 0 || p.X >= 3 || p.Y < 0 || p.Y >= 5 {
 		return nil
@@ -79,18 +129,63 @@ func (d *iDisplay) ButtonAt(p image.Point) benjamin.Button {
 }
 
 func (d *iDisplay) Events() <-chan benjamin.Event {
-	c := make(chan benjamin.Event)
+	c := make(chan benjamin.Event, 16)
 
 	go func(c chan<- benjamin.Event) {
 		defer close(c)
-		for {
 
+		p := make([]byte, 64)
+		for {
+			n, err := d.dev.Read(p)
+			if err != nil {
+				c <- benjamin.NewError(d, err)
+				return
+			}
+			d.handle(p[:n], c)
 		}
 	}(c)
 
 	return c
 }
 
+// handle a key state report. The report ID is followed by the state of all keys
+// as a little endian bit mask, bit 0 is the top left key and the keys are
+// numbered left to right, top to bottom; the bytes that follow are padding.
+// This is the report layout decoded by the node-infinitton-idisplay driver,
+// see https://github.com/bitfocus/node-infinitton-idisplay.
+func (d *iDisplay) handle(p []byte, c chan<- benjamin.Event) {
+	if len(p) < 3 {
+		return
+	}
+	state := binary.LittleEndian.Uint16(p[1:])
+	for i, k := range d.button {
+		press := state&(1<<i) != 0
+		if k.pressed != press {
+			k.pressed = press
+			if press {
+				k.press = time.Now()
+				c <- benjamin.NewButtonPress(d, k)
+			} else {
+				c <- benjamin.NewButtonRelease(d, k, time.Since(k.press))
+			}
+		}
+	}
+}
+
+func (d *iDisplay) Capabilities() benjamin.Capabilities {
+	c := benjamin.Capabilities{
+		Buttons:    make([]benjamin.PeripheralCapabilities, len(d.button)),
+		Brightness: benjamin.BrightnessRange{Min: 0, Max: 1, Steps: 101},
+
+		// Buttons go blank when idle, there is no standby image.
+		StandbyImage: false,
+	}
+	for i, k := range d.button {
+		c.Buttons[i] = k.Capabilities()
+	}
+	return c
+}
+
 func (d *iDisplay) SetBrightness(v float64) error {
 	if v < 0.0 {
 		v = 0.0
@@ -120,9 +215,75 @@ func (d *iDisplay) Index() int                   { return -1 }
 func (d *iDisplay) Size() image.Point            { return d.canvas.Rect.Max }
 func (d *iDisplay) Surface() benjamin.Surface    { return d }
 
+// Snapshot composes the last images set on the buttons in one image.
+func (d *iDisplay) Snapshot() (image.Image, error) {
+	o := image.NewNRGBA(image.Rectangle{Max: d.Size()})
+	for _, k := range d.button {
+		k.mu.Lock()
+		var (
+			p = k.Position().Mul(72)
+			s = k.frame
+		)
+		for y := 0; y < 72; y++ {
+			var (
+				i = s.PixOffset(s.Rect.Min.X, s.Rect.Min.Y+y)
+				j = o.PixOffset(p.X, p.Y+y)
+			)
+			for x := 0; x < 72; x++ {
+				o.Pix[j+0], o.Pix[j+1], o.Pix[j+2], o.Pix[j+3] = s.Pix[i+2], s.Pix[i+1], s.Pix[i+0], 0xff
+				i += 3
+				j += 4
+			}
+		}
+		k.mu.Unlock()
+	}
+	return o, nil
+}
+
+// ReplayOutput updates the images kept by the device from the output reports
+// and feature reports that were sent to an iDisplay, as returned by Output of a
+// transport.Replay. Snapshot then returns what that device showed. Nothing is
+// sent to the device.
+func (d *iDisplay) ReplayOutput(output []transport.Record) error {
+	var (
+		frame = make([]byte, 0, 72*72*3)
+		add   = func(p []byte) {
+			// The last page is padded.
+			if n := cap(frame) - len(frame); len(p) > n {
+				p = p[:n]
+			}
+			frame = append(frame, p...)
+		}
+	)
+	for _, r := range output {
+		p := []byte(r.Data)
+		switch {
+		case r.Op == transport.OpWrite && bytes.HasPrefix(p, headerPixelsPage1):
+			frame = frame[:0]
+			add(p[len(headerPixelsPage1):])
+		case r.Op == transport.OpWrite && bytes.HasPrefix(p, headerPixelsPage2):
+			add(p[len(headerPixelsPage2):])
+		case r.Op == transport.OpSendFeatureReport && len(p) > 5 && p[1] == reportPixels[1]:
+			// The pixel data is shown on the button in the report.
+			index := int(p[5]) - 1
+			if index < 0 || index >= len(d.button) || len(frame) < cap(frame) {
+				continue
+			}
+			k := d.button[index]
+			k.mu.Lock()
+			copy(k.frame.Pix, frame)
+			k.valid = false
+			k.mu.Unlock()
+		}
+	}
+	return nil
+}
+
 func (d *iDisplay) SetImage(i image.Image) error {
 	if i == nil {
 		return nil
+	} else if o, ok := i.(*image.NRGBA); ok && o.Rect.Size().Eq(d.canvas.Rect.Size()) {
+		imageutil.CopyNRGBA(d.canvas, image.Point{}, o, o.Rect)
 	} else if d.canvas.Rect.Eq(i.Bounds()) {
 		draw.Copy(d.canvas, image.Point{}, i, i.Bounds(), draw.Src, nil)
 	} else {
@@ -141,7 +302,7 @@ func (d *iDisplay) SetImage(i image.Image) error {
 			},
 		}
 		for x := 0; x < 3; x++ {
-			draw.Copy(d.buttonCanvas, image.Point{}, d.canvas, r, draw.Src, nil)
+			imageutil.CopyNRGBA(d.buttonCanvas, image.Point{}, d.canvas, r)
 			r.Min.X += 72
 			r.Max.X += 72
 			if err := d.button[o].SetImage(d.buttonCanvas); err != nil {
@@ -154,6 +315,9 @@ func (d *iDisplay) SetImage(i image.Image) error {
 }
 
 func init() {
-	driver.RegisterUSB(vendorID, iDisplayProductID, New)
-	driver.RegisterUSB(vendorID, iDisplayProductIDAlt, New)
+	open := func(info hid.DeviceInfo, open transport.Opener) benjamin.Device {
+		return NewIDisplayWithOpener(info, open)
+	}
+	driver.RegisterUSBWithOpener(vendorID, iDisplayProductID, open)
+	driver.RegisterUSBWithOpener(vendorID, iDisplayProductIDAlt, open)
 }
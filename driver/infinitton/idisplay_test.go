@@ -0,0 +1,214 @@
+package infinitton
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/karalabe/hid"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+func TestEvents(t *testing.T) {
+	m := transport.NewMemory()
+	d := NewIDisplayWithTransport(hid.DeviceInfo{}, m)
+	m.Feed([]byte{0x00, 0x00, 0x40}) // press key 14
+	m.Feed([]byte{0x00, 0x00, 0x00}) // release key 14
+	m.Close()
+
+	want := []benjamin.EventType{
+		benjamin.TypeButtonPress,
+		benjamin.TypeButtonRelease,
+		benjamin.TypeError,
+	}
+	var got []benjamin.Event
+	for event := range d.Events() {
+		got = append(got, event)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(got))
+	}
+	for i, event := range got {
+		if event.Type != want[i] {
+			t.Errorf("event %d: expected %s, got %s", i, want[i], event.Type)
+		}
+		if event.Peripheral != nil && event.Peripheral != d.Button(14) {
+			t.Errorf("event %d: expected button 14, got %d", i, event.Peripheral.Index())
+		}
+	}
+}
+
+func TestKeyReport(t *testing.T) {
+	d := NewIDisplayWithTransport(hid.DeviceInfo{}, transport.NewMemory())
+
+	// Keys 0, 7 and 8 pressed, in a report padded to the input report size.
+	p := make([]byte, 64)
+	p[1], p[2] = 0x81, 0x01
+	c := make(chan benjamin.Event, len(d.button))
+	d.handle(p, c)
+	close(c)
+
+	var pressed []int
+	for event := range c {
+		if event.Type != benjamin.TypeButtonPress {
+			t.Fatalf("expected button press, got %s", event)
+		}
+		pressed = append(pressed, event.Peripheral.Index())
+	}
+	if want := []int{0, 7, 8}; fmt.Sprint(pressed) != fmt.Sprint(want) {
+		t.Errorf("expected keys %v pressed, got %v", want, pressed)
+	}
+}
+
+func TestSkipUnchanged(t *testing.T) {
+	m := transport.NewMemory()
+	d := NewIDisplayWithTransport(hid.DeviceInfo{}, m)
+	i := image.NewNRGBA(image.Rect(0, 0, 72, 72))
+	for n := 0; n < 2; n++ {
+		if err := d.Button(0).SetImage(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(m.FeatureReports()); n != 1 {
+		t.Errorf("expected 1 frame to be sent, got %d", n)
+	}
+
+	d.Invalidate()
+	if err := d.Button(0).SetImage(i); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.FeatureReports()); n != 2 {
+		t.Errorf("expected frame to be sent after invalidate, got %d frames", n)
+	}
+}
+
+func TestWritePixelData(t *testing.T) {
+	m := transport.NewMemory()
+	d := NewIDisplayWithTransport(hid.DeviceInfo{}, m)
+	i := image.NewNRGBA(image.Rect(0, 0, 72, 72))
+	i.SetNRGBA(0, 0, color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff})
+	if err := d.Button(0).SetImage(i); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := m.Writes()
+	if len(writes) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(writes))
+	}
+	for n, h := range [][]byte{headerPixelsPage1, headerPixelsPage2} {
+		if p := writes[n]; len(p) != pagePacketSize || !bytes.HasPrefix(p, h) {
+			t.Errorf("page %d: expected %d bytes with header % x", n+1, pagePacketSize, h)
+		}
+	}
+	if p := writes[0][len(headerPixelsPage1):]; !bytes.HasPrefix(p, []byte{0x33, 0x22, 0x11}) {
+		t.Errorf("expected BGR pixel data, got % x", p[:3])
+	}
+}
+
+// nullTransport discards all writes.
+type nullTransport struct {
+	*transport.Memory
+}
+
+func (nullTransport) Write(p []byte) (int, error)             { return len(p), nil }
+func (nullTransport) SendFeatureReport(p []byte) (int, error) { return len(p), nil }
+
+func BenchmarkButtonSetImage(b *testing.B) {
+	var (
+		d      = NewIDisplayWithTransport(hid.DeviceInfo{}, nullTransport{transport.NewMemory()})
+		images [2]*image.NRGBA
+	)
+	for n := range images {
+		images[n] = image.NewNRGBA(image.Rect(0, 0, 72, 72))
+		images[n].SetNRGBA(0, 0, color.NRGBA{R: uint8(n), A: 0xff})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := d.Button(0).SetImage(images[n&1]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkButtonAreaSetImage(b *testing.B) {
+	var (
+		d      = NewIDisplayWithTransport(hid.DeviceInfo{}, nullTransport{transport.NewMemory()})
+		images [2]*image.NRGBA
+	)
+	for n := range images {
+		images[n] = image.NewNRGBA(image.Rectangle{Max: d.Size()})
+		for o := 0; o < len(images[n].Pix); o += 4 {
+			images[n].Pix[o+n] = 0xff
+			images[n].Pix[o+3] = 0xff
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := d.ButtonArea().SetImage(images[n&1]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	d := NewIDisplayWithTransport(hid.DeviceInfo{}, transport.NewMemory())
+	i := image.NewNRGBA(image.Rect(0, 0, 72, 72))
+	for o := 0; o < len(i.Pix); o += 4 {
+		i.Pix[o+0], i.Pix[o+3] = 0xff, 0xff
+	}
+	if err := d.Button(4).SetImage(i); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := d.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	if c := s.At(72+10, 72+10); c != red {
+		t.Errorf("expected button 4 to be red, got %v", c)
+	}
+	if c := s.At(10, 10); c != (color.NRGBA{A: 0xff}) {
+		t.Errorf("expected button 0 to be black, got %v", c)
+	}
+}
+
+func TestReplayOutput(t *testing.T) {
+	var (
+		capture bytes.Buffer
+		d       = NewIDisplayWithTransport(hid.DeviceInfo{}, transport.NewCapture(transport.NewMemory(), hid.DeviceInfo{}, &capture))
+		i       = image.NewNRGBA(image.Rect(0, 0, 72, 72))
+	)
+	for o := 0; o < len(i.Pix); o += 4 {
+		i.Pix[o+1], i.Pix[o+3] = 0xff, 0xff
+	}
+	if err := d.Button(7).SetImage(i); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := transport.NewReplay(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewIDisplayWithTransport(hid.DeviceInfo{}, replay)
+	if err = r.ReplayOutput(replay.Output()); err != nil {
+		t.Fatal(err)
+	}
+	s, err := r.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	green := color.NRGBA{G: 0xff, A: 0xff}
+	if c := s.At(72+71, 2*72+71); c != green {
+		t.Errorf("expected button 7 to be green, got %v", c)
+	}
+	if c := s.At(72+10, 72+10); c != (color.NRGBA{A: 0xff}) {
+		t.Errorf("expected button 4 to be black, got %v", c)
+	}
+}
@@ -0,0 +1,172 @@
+package driver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+// Manager opens all devices matching its filters and keeps track of them
+// across hotplug events. Devices are wrapped in a Reconnecting device, so the
+// devices and peripherals returned by the Manager stay valid when a device is
+// unplugged and plugged back in.
+//
+// Events of all devices are merged into a single channel, use the Device of
+// the event data (or IDOf) to find out which device it originated from.
+type Manager struct {
+	open    transport.Opener
+	filters []Filter
+	mu      sync.Mutex
+	devices map[string]*Reconnecting
+	order   []*Reconnecting
+	events  chan benjamin.Event
+	cancel  context.CancelFunc
+	wait    sync.WaitGroup
+	once    sync.Once
+}
+
+// Manage starts a Manager for all devices matching all filters, until the
+// context is done or the Manager is closed.
+func Manage(ctx context.Context, filters ...Filter) *Manager {
+	return ManageWith(ctx, transport.Open, filters...)
+}
+
+// ManageWith is like Manage, but USB devices are opened with the supplied
+// Opener, also when they reconnect.
+func ManageWith(ctx context.Context, open transport.Opener, filters ...Filter) *Manager {
+	ctx, cancel := context.WithCancel(ctx)
+	m := &Manager{
+		open:    open,
+		filters: filters,
+		devices: make(map[string]*Reconnecting),
+		events:  make(chan benjamin.Event, 64),
+		cancel:  cancel,
+	}
+	m.wait.Add(1)
+	go m.watch(ctx)
+	return m
+}
+
+// Events returns the merged events of all devices.
+func (m *Manager) Events() <-chan benjamin.Event {
+	return m.events
+}
+
+// Devices returns all devices seen by the Manager, in the order they were
+// first attached. Devices that are currently unplugged are included.
+func (m *Manager) Devices() []*Reconnecting {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Reconnecting(nil), m.order...)
+}
+
+// Device returns the device with the supplied ID, or nil if it is unknown.
+func (m *Manager) Device(id ID) *Reconnecting {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.devices[id.Key()]
+}
+
+// Button returns the button at index on the device with the supplied ID.
+func (m *Manager) Button(id ID, index int) benjamin.Button {
+	if d := m.Device(id); d != nil {
+		return d.Button(index)
+	}
+	return nil
+}
+
+// Display returns the display at index on the device with the supplied ID.
+func (m *Manager) Display(id ID, index int) benjamin.Display {
+	if d := m.Device(id); d != nil {
+		return d.Display(index)
+	}
+	return nil
+}
+
+// Encoder returns the encoder at index on the device with the supplied ID.
+func (m *Manager) Encoder(id ID, index int) benjamin.Encoder {
+	if d := m.Device(id); d != nil {
+		return d.Encoder(index)
+	}
+	return nil
+}
+
+// Close all devices and stop watching, this closes the Events channel.
+func (m *Manager) Close() error {
+	m.cancel()
+
+	var err error
+	for _, d := range m.Devices() {
+		if cerr := d.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	m.once.Do(func() {
+		go func() {
+			m.wait.Wait()
+			close(m.events)
+		}()
+	})
+	return err
+}
+
+func (m *Manager) watch(ctx context.Context) {
+	defer m.wait.Done()
+	defer m.Close()
+
+	for event := range WatchWith(ctx, m.open) {
+		if event.Type != Attach || !matches(event.Device, m.filters) {
+			continue
+		}
+		m.attach(ctx, event)
+	}
+}
+
+func (m *Manager) attach(ctx context.Context, event HotplugEvent) {
+	key := event.ID.Key()
+
+	m.mu.Lock()
+	d, known := m.devices[key]
+	m.mu.Unlock()
+
+	if known {
+		// The wrapper reconnects by itself, it watches the same scans.
+		return
+	}
+
+	d = ReconnectWith(event.Device, m.open)
+	if err := d.Open(); err != nil {
+		// Not accessible, the device is retried when it is attached again.
+		m.emit(ctx, benjamin.NewError(d, err))
+		return
+	}
+
+	m.mu.Lock()
+	m.devices[key] = d
+	m.order = append(m.order, d)
+	m.mu.Unlock()
+
+	m.wait.Add(1)
+	go m.forward(ctx, d)
+}
+
+// forward events of a device to the merged Events channel.
+func (m *Manager) forward(ctx context.Context, d *Reconnecting) {
+	defer m.wait.Done()
+	for event := range d.Events() {
+		if !m.emit(ctx, event) {
+			return
+		}
+	}
+}
+
+func (m *Manager) emit(ctx context.Context, event benjamin.Event) bool {
+	select {
+	case m.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,89 @@
+package driver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver"
+	"github.com/tehmaze/benjamin/driver/mock"
+)
+
+type managerDevice struct {
+	*mock.Mock
+	serial string
+}
+
+func (d managerDevice) Serial() string { return d.serial }
+
+func TestManager(t *testing.T) {
+	for _, serial := range []string{"manager-a", "manager-b"} {
+		serial := serial
+		driver.RegisterForTest(t, func() bool { return true }, func() benjamin.Device {
+			return managerDevice{mock.NewWithLayout(mock.DefaultLayout), serial}
+		})
+	}
+	driver.SetWatchIntervalForTest(t, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	m := driver.Manage(ctx, driver.ByGlob("manager-*"))
+	defer func() {
+		m.Close()
+		for range m.Events() {
+			// Wait for the Manager to stop watching.
+		}
+	}()
+
+	attached := make(map[string]bool)
+	for len(attached) < 2 {
+		select {
+		case event := <-m.Events():
+			if event.Type == benjamin.TypeDeviceAttach {
+				attached[event.Data.Device().Serial()] = true
+			}
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for devices, got %v", attached)
+		}
+	}
+	var b *mock.Mock
+	for _, d := range m.Devices() {
+		if d.Serial() == "manager-b" {
+			b = d.Connected().(managerDevice).Mock
+			if m.Device(d.ID()) != d {
+				t.Errorf("expected device %s to be found by ID", d.ID())
+			}
+		}
+	}
+	if b == nil {
+		t.Fatal("expected manager-b to be connected")
+	}
+
+	want := m.Button(driver.IDOf(managerDevice{b, "manager-b"}), 3)
+	if want == nil {
+		t.Fatal("expected button 3 on manager-b")
+	}
+
+	r := make(benjamin.Router)
+	pressed := make(chan struct{}, 1)
+	r.On(want, benjamin.TypeButtonPress, benjamin.EventHandlerFunc(func(benjamin.Event) {
+		pressed <- struct{}{}
+	}))
+
+	b.Press(3)
+	for {
+		select {
+		case event := <-m.Events():
+			r.Handle(event)
+		case <-pressed:
+			if n := len(m.Devices()); n != 2 {
+				t.Errorf("expected 2 devices, got %d", n)
+			}
+			return
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for button press")
+		}
+	}
+}
@@ -1,57 +1,439 @@
+// Package mock contains an in-memory device, for testing applications without
+// hardware.
 package mock
 
 import (
 	"image"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
 
 	"github.com/tehmaze/benjamin"
 	"github.com/tehmaze/benjamin/driver"
+	"github.com/tehmaze/benjamin/driver/streamdeck"
 )
 
-var (
+// Layout of the mock device peripherals, buttons without screens have a zero
+// ButtonSize.
+type Layout struct {
+	ButtonLayout  image.Point // in cols x rows
+	ButtonSize    image.Point // in pixels
+	Displays      int         //
+	DisplayLayout image.Point // in cols x rows
+	DisplaySize   image.Point // in pixels
+	Encoders      int         //
+}
+
+// DefaultLayout is the layout used by New, it resembles a Stream Deck MK.2.
+var DefaultLayout = LayoutOf(streamdeck.MK2)
+
+// LayoutOf returns the layout of a Stream Deck model.
+func LayoutOf(prop streamdeck.Properties) Layout {
+	return Layout{
+		ButtonLayout:  prop.ButtonLayout(),
+		ButtonSize:    prop.ButtonSize(),
+		Displays:      prop.Displays(),
+		DisplayLayout: prop.DisplayLayout(),
+		DisplaySize:   prop.DisplaySize(),
+		Encoders:      prop.Encoders(),
+	}
+}
+
+// Mock device.
+type Mock struct {
+	// Errors returned by the respective methods.
 	ErrOpen          error
 	ErrClose         error
 	ErrReset         error
 	ErrClear         error
 	ErrSetBrightness error
-	Displays         int
-	Encoders         int
-	Buttons             int
-	ButtonLayout        image.Point
-	ButtonSize          image.Point
-)
 
-// Mock interface
-type Mock struct {
+	layout      Layout
+	mu          sync.Mutex
+	button      []*Button
+	buttonArea  *Screen
+	display     []*Display
+	displayArea *Screen
+	encoder     []*Encoder
+	events      chan benjamin.Event
+	eventsMu    sync.RWMutex // held for writing while closing events
+	done        chan struct{}
+	closeOnce   sync.Once
+	brightness  []float64
+	resets      int
+	clears      int
 }
 
+// New mock device with the DefaultLayout.
 func New() benjamin.Device {
-	return new(Mock)
-}
-
-func (Mock) Manufacturer() string           { return "maze.io" }
-func (Mock) Product() string                { return "mock" }
-func (Mock) Serial() string                 { return "2342" }
-func (Mock) Open() error                    { return ErrOpen }
-func (Mock) Close() error                   { return ErrClose }
-func (Mock) Reset() error                   { return ErrReset }
-func (Mock) Clear() error                   { return ErrClear }
-func (Mock) Display(int) benjamin.Display   { return nil }
-func (Mock) Displays() int                  { return Displays }
-func (Mock) Encoder(int) benjamin.Encoder   { return nil }
-func (Mock) Encoders() int                  { return Encoders }
-func (Mock) Button(int) benjamin.Button           { return nil }
-func (Mock) ButtonAt(image.Point) benjamin.Button { return nil }
-func (Mock) ButtonLayout() image.Point         { return ButtonLayout }
-func (Mock) ButtonSize() image.Point           { return ButtonSize }
-func (Mock) Buttons() int                      { return Buttons }
-func (Mock) SetBrightness(float64) error    { return ErrSetBrightness }
-
-func (Mock) Events() <-chan benjamin.Event {
-	c := make(chan benjamin.Event)
-	close(c)
-	return c
+	return NewWithLayout(DefaultLayout)
+}
+
+// NewWithLayout returns a mock device with the supplied layout.
+func NewWithLayout(layout Layout) *Mock {
+	m := &Mock{
+		layout:  layout,
+		button:  make([]*Button, layout.ButtonLayout.X*layout.ButtonLayout.Y),
+		display: make([]*Display, layout.Displays),
+		encoder: make([]*Encoder, layout.Encoders),
+		events:  make(chan benjamin.Event, 64),
+		done:    make(chan struct{}),
+	}
+	for i := range m.button {
+		m.button[i] = &Button{
+			drawable: drawable{size: layout.ButtonSize},
+			device:   m,
+			index:    i,
+			pos:      image.Pt(i%layout.ButtonLayout.X, i/layout.ButtonLayout.X),
+		}
+	}
+	for i := range m.display {
+		m.display[i] = &Display{
+			drawable: drawable{size: layout.DisplaySize},
+			device:   m,
+			index:    i,
+		}
+	}
+	for i := range m.encoder {
+		m.encoder[i] = &Encoder{
+			device: m,
+			index:  i,
+		}
+	}
+	if !layout.ButtonSize.Eq(image.Point{}) {
+		m.buttonArea = &Screen{
+			drawable: drawable{size: image.Pt(
+				layout.ButtonLayout.X*layout.ButtonSize.X,
+				layout.ButtonLayout.Y*layout.ButtonSize.Y,
+			)},
+			device: m,
+			layout: layout.ButtonLayout,
+		}
+		for _, b := range m.button {
+			m.buttonArea.parts = append(m.buttonArea.parts, &b.drawable)
+		}
+	}
+	if layout.Displays > 0 {
+		m.displayArea = &Screen{
+			drawable: drawable{size: image.Pt(
+				layout.DisplayLayout.X*layout.DisplaySize.X,
+				layout.DisplayLayout.Y*layout.DisplaySize.Y,
+			)},
+			device: m,
+			layout: layout.DisplayLayout,
+		}
+		for _, d := range m.display {
+			m.displayArea.parts = append(m.displayArea.parts, &d.drawable)
+		}
+	}
+	return m
+}
+
+func (*Mock) Manufacturer() string { return "maze.io" }
+func (*Mock) Product() string      { return "mock" }
+func (*Mock) Serial() string       { return "2342" }
+
+func (m *Mock) Open() error { return m.ErrOpen }
+
+// Close the device, this closes the Events channel.
+func (m *Mock) Close() error {
+	m.closeOnce.Do(func() {
+		// Unblock pending calls to Send before closing the channel.
+		close(m.done)
+		m.eventsMu.Lock()
+		close(m.events)
+		m.eventsMu.Unlock()
+	})
+	return m.ErrClose
+}
+
+func (m *Mock) Reset() error {
+	m.mu.Lock()
+	m.resets++
+	m.mu.Unlock()
+	return m.ErrReset
+}
+
+func (m *Mock) Clear() error {
+	m.mu.Lock()
+	m.clears++
+	m.mu.Unlock()
+	if m.ErrClear != nil {
+		return m.ErrClear
+	}
+	for _, b := range m.button {
+		if benjamin.HasScreen(b) {
+			b.set(image.Black)
+		}
+	}
+	for _, d := range m.display {
+		d.set(image.Black)
+	}
+	return nil
+}
+
+func (m *Mock) SetBrightness(v float64) error {
+	m.mu.Lock()
+	m.brightness = append(m.brightness, v)
+	m.mu.Unlock()
+	return m.ErrSetBrightness
+}
+
+func (m *Mock) Display(index int) benjamin.Display {
+	if index < 0 || index >= len(m.display) {
+		return nil
+	}
+	return m.display[index]
+}
+
+func (m *Mock) Displays() int { return len(m.display) }
+
+func (m *Mock) DisplayArea() benjamin.Screen {
+	if m.displayArea == nil {
+		return nil
+	}
+	return m.displayArea
+}
+
+func (m *Mock) Encoder(index int) benjamin.Encoder {
+	if index < 0 || index >= len(m.encoder) {
+		return nil
+	}
+	return m.encoder[index]
+}
+
+func (m *Mock) Encoders() int { return len(m.encoder) }
+
+func (m *Mock) Button(index int) benjamin.Button {
+	if index < 0 || index >= len(m.button) {
+		return nil
+	}
+	return m.button[index]
+}
+
+func (m *Mock) ButtonAt(p image.Point) benjamin.Button {
+	if p.X < 0 || p.X >= m.layout.ButtonLayout.X || p.Y < 0 || p.Y >= m.layout.ButtonLayout.Y {
+		return nil
+	}
+	return m.button[p.Y*m.layout.ButtonLayout.X+p.X]
+}
+
+func (m *Mock) Buttons() int                  { return len(m.button) }
+func (m *Mock) ButtonLayout() image.Point     { return m.layout.ButtonLayout }
+func (m *Mock) ButtonSize() image.Point       { return m.layout.ButtonSize }
+func (m *Mock) ButtonArea() benjamin.Screen   { return m.buttonArea }
+func (m *Mock) Events() <-chan benjamin.Event { return m.events }
+
+// Brightness returns all brightness values set, in order.
+func (m *Mock) Brightness() []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]float64(nil), m.brightness...)
+}
+
+// Resets returns the number of calls to Reset.
+func (m *Mock) Resets() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.resets
+}
+
+// Clears returns the number of calls to Clear.
+func (m *Mock) Clears() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.clears
+}
+
+// Send an event, the event is dropped if the device is closed. Send blocks if
+// the Events channel buffer is full, until the event is received or the device
+// is closed.
+func (m *Mock) Send(event benjamin.Event) {
+	m.eventsMu.RLock()
+	defer m.eventsMu.RUnlock()
+	select {
+	case <-m.done:
+		return
+	default:
+	}
+	select {
+	case m.events <- event:
+	case <-m.done:
+	}
+}
+
+// Press button at index.
+func (m *Mock) Press(index int) {
+	b := m.button[index]
+	b.press = time.Now()
+	m.Send(benjamin.NewButtonPress(m, b))
+}
+
+// Release button at index.
+func (m *Mock) Release(index int) {
+	b := m.button[index]
+	m.Send(benjamin.NewButtonRelease(m, b, time.Since(b.press)))
+}
+
+// Turn encoder at index by change steps.
+func (m *Mock) Turn(index, change int) {
+	m.Send(benjamin.NewEncoderChange(m, m.encoder[index], change, 8))
+}
+
+// PressEncoder presses the encoder at index.
+func (m *Mock) PressEncoder(index int) {
+	e := m.encoder[index]
+	e.press = time.Now()
+	m.Send(benjamin.NewEncoderPress(m, e))
+}
+
+// ReleaseEncoder releases the encoder at index.
+func (m *Mock) ReleaseEncoder(index int) {
+	e := m.encoder[index]
+	m.Send(benjamin.NewEncoderRelease(m, e, time.Since(e.press)))
+}
+
+// Touch the display at index.
+func (m *Mock) Touch(index int, at image.Point) {
+	m.Send(benjamin.NewDisplayPress(m, m.display[index], at))
+}
+
+// LongTouch long presses the display at index.
+func (m *Mock) LongTouch(index int, at image.Point) {
+	m.Send(benjamin.NewDisplayLongPress(m, m.display[index], at))
+}
+
+// Swipe over the display at index.
+func (m *Mock) Swipe(index int, from, to image.Point) {
+	m.Send(benjamin.NewDisplaySwipe(m, m.display[index], from, to))
+}
+
+// Fail sends an error event.
+func (m *Mock) Fail(err error) {
+	m.Send(benjamin.NewError(m, err))
+}
+
+type drawable struct {
+	mu    sync.Mutex
+	size  image.Point
+	image *image.NRGBA
+}
+
+func (d *drawable) Size() image.Point {
+	return d.size
+}
+
+// Image returns a copy of the last image set, or nil if no image was set.
+func (d *drawable) Image() *image.NRGBA {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.image == nil {
+		return nil
+	}
+	o := image.NewNRGBA(d.image.Rect)
+	copy(o.Pix, d.image.Pix)
+	return o
+}
+
+// SetImage keeps a copy of the image, drawables with a zero size have no
+// screen and return benjamin.ErrNotSupported.
+func (d *drawable) SetImage(i image.Image) error {
+	if !benjamin.HasScreen(d) {
+		return benjamin.ErrNotSupported
+	}
+	d.set(i)
+	return nil
+}
+
+func (d *drawable) set(i image.Image) {
+	o := image.NewNRGBA(image.Rectangle{Max: d.size})
+	if i != nil {
+		if _, ok := i.(*image.Uniform); ok || i.Bounds().Size().Eq(d.size) {
+			draw.Draw(o, o.Rect, i, i.Bounds().Min, draw.Src)
+		} else {
+			draw.BiLinear.Scale(o, o.Rect, i, i.Bounds(), draw.Src, nil)
+		}
+	}
+	d.mu.Lock()
+	d.image = o
+	d.mu.Unlock()
+}
+
+// Button on the mock device.
+type Button struct {
+	drawable
+	device *Mock
+	index  int
+	pos    image.Point
+	press  time.Time
+}
+
+func (b *Button) Surface() benjamin.Surface { return b.device }
+func (b *Button) Index() int                { return b.index }
+func (b *Button) Position() image.Point     { return b.pos }
+
+// Display on the mock device.
+type Display struct {
+	drawable
+	device *Mock
+	index  int
+}
+
+func (d *Display) Surface() benjamin.Surface { return d.device }
+func (d *Display) Index() int                { return d.index }
+
+// Encoder on the mock device.
+type Encoder struct {
+	device *Mock
+	index  int
+	press  time.Time
+}
+
+func (e *Encoder) Surface() benjamin.Surface { return e.device }
+func (e *Encoder) Index() int                { return e.index }
+
+func (e *Encoder) Display() benjamin.Display {
+	return e.device.Display(e.index)
+}
+
+// Screen is a virtual screen spanning all buttons or all displays.
+type Screen struct {
+	drawable
+	device *Mock
+	layout image.Point
+	parts  []*drawable
+}
+
+func (s *Screen) Surface() benjamin.Surface { return s.device }
+func (s *Screen) Index() int                { return -1 }
+
+// SetImage sets the image of the area and slices it across its peripherals.
+func (s *Screen) SetImage(i image.Image) error {
+	s.set(i)
+	canvas := s.Image()
+	for n, part := range s.parts {
+		var (
+			x = n % s.layout.X
+			y = n / s.layout.X
+			r = image.Rectangle{
+				Min: image.Pt(x*part.size.X, y*part.size.Y),
+				Max: image.Pt((x+1)*part.size.X, (y+1)*part.size.Y),
+			}
+		)
+		part.set(canvas.SubImage(r))
+	}
+	return nil
 }
 
 func init() {
 	driver.Register(func() bool { return true }, New)
 }
+
+var (
+	_ benjamin.Device  = (*Mock)(nil)
+	_ benjamin.Button  = (*Button)(nil)
+	_ benjamin.Display = (*Display)(nil)
+	_ benjamin.Encoder = (*Encoder)(nil)
+	_ benjamin.Screen  = (*Screen)(nil)
+)
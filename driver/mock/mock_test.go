@@ -0,0 +1,114 @@
+package mock
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/streamdeck"
+)
+
+func TestMock(t *testing.T) {
+	m := NewWithLayout(LayoutOf(streamdeck.Plus))
+	if n := m.Buttons(); n != 8 {
+		t.Errorf("expected 8 buttons, got %d", n)
+	}
+	if n := m.Displays(); n != 4 {
+		t.Errorf("expected 4 displays, got %d", n)
+	}
+
+	if err := m.Button(3).SetImage(image.NewUniform(color.White)); err != nil {
+		t.Fatal(err)
+	}
+	if c := m.Button(3).(*Button).Image().NRGBAAt(0, 0); c != (color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+		t.Errorf("expected white button, got %v", c)
+	}
+
+	m.Press(3)
+	m.Release(3)
+	m.Turn(1, -2)
+	_ = m.SetBrightness(0.5)
+	_ = m.Close()
+
+	var types []benjamin.EventType
+	for event := range m.Events() {
+		types = append(types, event.Type)
+	}
+	if len(types) != 3 || types[0] != benjamin.TypeButtonPress || types[2] != benjamin.TypeEncoderChange {
+		t.Errorf("unexpected events %v", types)
+	}
+	if b := m.Brightness(); len(b) != 1 || b[0] != 0.5 {
+		t.Errorf("expected brightness [0.5], got %v", b)
+	}
+}
+
+func TestCloseWhileSending(t *testing.T) {
+	m := New().(*Mock)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < cap(m.events)+1; i++ {
+			m.Press(0)
+		}
+	}()
+	for len(m.events) < cap(m.events) {
+		time.Sleep(time.Millisecond)
+	}
+	_ = m.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Send blocked after Close")
+	}
+}
+
+func TestCapabilitiesOf(t *testing.T) {
+	for _, prop := range []streamdeck.Properties{
+		streamdeck.Orig,
+		streamdeck.Mini,
+		streamdeck.XL,
+		streamdeck.Plus,
+		streamdeck.Pedal,
+	} {
+		t.Run(prop.Model, func(t *testing.T) {
+			var (
+				m       = NewWithLayout(LayoutOf(prop))
+				c       = benjamin.CapabilitiesOf(m)
+				button  = benjamin.PeripheralCapabilities{Push: true}
+				display benjamin.PeripheralCapabilities
+				encoder = benjamin.PeripheralCapabilities{Push: true}
+			)
+			if size := prop.ButtonSize(); !size.Eq(image.Point{}) {
+				button.Screen, button.Size = true, size
+			}
+			if size := prop.DisplaySize(); !size.Eq(image.Point{}) {
+				display.Screen, display.Size = true, size
+			}
+			for _, test := range []struct {
+				name  string
+				got   []benjamin.PeripheralCapabilities
+				count int
+				want  benjamin.PeripheralCapabilities
+				at    func(int) benjamin.Peripheral
+			}{
+				{"button", c.Buttons, m.Buttons(), button, func(i int) benjamin.Peripheral { return m.Button(i) }},
+				{"display", c.Displays, m.Displays(), display, func(i int) benjamin.Peripheral { return m.Display(i) }},
+				{"encoder", c.Encoders, m.Encoders(), encoder, func(i int) benjamin.Peripheral { return m.Encoder(i) }},
+			} {
+				if len(test.got) != test.count {
+					t.Fatalf("expected %d %ss, got %d", test.count, test.name, len(test.got))
+				}
+				for i, got := range test.got {
+					if got != test.want {
+						t.Errorf("%s %d: expected %s, got %s", test.name, i, test.want, got)
+					}
+					if p := benjamin.PeripheralCapabilitiesOf(test.at(i)); p != test.want {
+						t.Errorf("%s %d: expected peripheral %s, got %s", test.name, i, test.want, p)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,655 @@
+package driver
+
+import (
+	"context"
+	"image"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/karalabe/hid"
+	"golang.org/x/image/draw"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+// Reconnecting is a device that survives disconnects. When the underlying
+// device disappears, it waits for a device with the same ID to reappear,
+// re-applies the brightness and device settings, such as the rotation, and
+// replays the last image on every button and display.
+//
+// The peripherals returned by Reconnecting and the channel returned by Events
+// stay valid across reconnects. A TypeDeviceAttach event is emitted when the
+// device is opened, TypeDeviceDetach and TypeDeviceReconnect events are emitted
+// when the underlying device goes away or comes back.
+type Reconnecting struct {
+	id          ID
+	open        transport.Opener
+	mu          sync.Mutex
+	device      benjamin.Device // connected device, nil while disconnected
+	last        benjamin.Device // last connected device
+	brightness  float64
+	bright      bool
+	seq         uint64
+	button      []*reconnectingButton
+	buttonArea  *reconnectingScreen
+	display     []*reconnectingDisplay
+	displayArea *reconnectingScreen
+	encoder     []*reconnectingEncoder
+	events      chan benjamin.Event
+	running     bool
+	done        chan struct{}
+	once        sync.Once
+}
+
+// Reconnect wraps device, the device should not be opened yet.
+func Reconnect(device benjamin.Device) *Reconnecting {
+	return ReconnectWith(device, transport.Open)
+}
+
+// ReconnectWith is like Reconnect, but the reappeared USB device is opened with
+// the supplied Opener.
+func ReconnectWith(device benjamin.Device, open transport.Opener) *Reconnecting {
+	r := &Reconnecting{
+		id:      IDOf(device),
+		open:    open,
+		last:    device,
+		button:  make([]*reconnectingButton, device.Buttons()),
+		display: make([]*reconnectingDisplay, device.Displays()),
+		encoder: make([]*reconnectingEncoder, device.Encoders()),
+		events:  make(chan benjamin.Event, 16),
+		done:    make(chan struct{}),
+	}
+	for i := range r.button {
+		i := i
+		if b := device.Button(i); b != nil {
+			r.button[i] = &reconnectingButton{
+				shadow: newShadow(r, i, b.Size(), func(d benjamin.Device) benjamin.Drawable {
+					if b := d.Button(i); b != nil {
+						return b
+					}
+					return nil
+				}),
+				pos: b.Position(),
+			}
+		}
+	}
+	for i := range r.display {
+		i := i
+		if d := device.Display(i); d != nil {
+			r.display[i] = &reconnectingDisplay{
+				shadow: newShadow(r, i, d.Size(), func(d benjamin.Device) benjamin.Drawable {
+					if d := d.Display(i); d != nil {
+						return d
+					}
+					return nil
+				}),
+			}
+		}
+	}
+	for i := range r.encoder {
+		r.encoder[i] = &reconnectingEncoder{device: r, index: i}
+		if e := device.Encoder(i); e != nil {
+			if d := e.Display(); d != nil && d.Index() < len(r.display) {
+				r.encoder[i].display = r.display[d.Index()]
+			}
+		}
+	}
+	if s := device.ButtonArea(); s != nil {
+		r.buttonArea = &reconnectingScreen{
+			shadow: newShadow(r, -1, s.Size(), func(d benjamin.Device) benjamin.Drawable {
+				if s := d.ButtonArea(); s != nil {
+					return s
+				}
+				return nil
+			}),
+		}
+	}
+	if s := device.DisplayArea(); s != nil {
+		r.displayArea = &reconnectingScreen{
+			shadow: newShadow(r, -1, s.Size(), func(d benjamin.Device) benjamin.Drawable {
+				if s := d.DisplayArea(); s != nil {
+					return s
+				}
+				return nil
+			}),
+		}
+	}
+	return r
+}
+
+// ID of the wrapped device.
+func (r *Reconnecting) ID() ID { return r.id }
+
+// Connected returns the connected device, or nil if the device is disconnected.
+func (r *Reconnecting) Connected() benjamin.Device {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.device
+}
+
+func (r *Reconnecting) lastDevice() benjamin.Device {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+func (r *Reconnecting) Manufacturer() string { return r.lastDevice().Manufacturer() }
+func (r *Reconnecting) Product() string      { return r.lastDevice().Product() }
+func (r *Reconnecting) Serial() string       { return r.lastDevice().Serial() }
+func (r *Reconnecting) Model() string        { return modelOf(r.lastDevice()) }
+
+func (r *Reconnecting) DeviceInfo() hid.DeviceInfo {
+	if usb, ok := r.lastDevice().(benjamin.USBDevice); ok {
+		return usb.DeviceInfo()
+	}
+	return hid.DeviceInfo{}
+}
+
+// Open the wrapped device and start monitoring it.
+func (r *Reconnecting) Open() error {
+	r.mu.Lock()
+	if r.device != nil {
+		r.mu.Unlock()
+		return nil
+	}
+	device := r.last
+	r.mu.Unlock()
+
+	if err := device.Open(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.device = device
+	if !r.running {
+		r.running = true
+		go r.run(device)
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// Close the device, this stops reconnecting and closes the Events channel.
+func (r *Reconnecting) Close() error {
+	r.mu.Lock()
+	r.once.Do(func() {
+		close(r.done)
+		if !r.running {
+			close(r.events)
+		}
+	})
+	device := r.device
+	r.mu.Unlock()
+	if device != nil {
+		return device.Close()
+	}
+	return nil
+}
+
+func (r *Reconnecting) Reset() error {
+	r.mu.Lock()
+	for _, s := range r.shadows() {
+		s.image = nil
+	}
+	device := r.device
+	r.mu.Unlock()
+
+	if device == nil {
+		return nil
+	}
+	return device.Reset()
+}
+
+func (r *Reconnecting) Clear() error {
+	for _, b := range r.button {
+		if b != nil && benjamin.HasScreen(b) {
+			b.keep(image.Black)
+		}
+	}
+	for _, d := range r.display {
+		if d != nil {
+			d.keep(image.Black)
+		}
+	}
+
+	if device := r.Connected(); device != nil {
+		return device.Clear()
+	}
+	return nil
+}
+
+func (r *Reconnecting) SetBrightness(v float64) error {
+	r.mu.Lock()
+	r.brightness, r.bright = v, true
+	device := r.device
+	r.mu.Unlock()
+
+	if device == nil {
+		return nil
+	}
+	return device.SetBrightness(v)
+}
+
+// Events returns the event channel, the same channel is returned for every call.
+func (r *Reconnecting) Events() <-chan benjamin.Event {
+	return r.events
+}
+
+func (r *Reconnecting) Capabilities() benjamin.Capabilities {
+	return benjamin.CapabilitiesOf(r.lastDevice())
+}
+
+func (r *Reconnecting) Display(index int) benjamin.Display {
+	if index < 0 || index >= len(r.display) || r.display[index] == nil {
+		return nil
+	}
+	return r.display[index]
+}
+
+func (r *Reconnecting) Displays() int {
+	return len(r.display)
+}
+
+func (r *Reconnecting) DisplayArea() benjamin.Screen {
+	if r.displayArea == nil {
+		return nil
+	}
+	return r.displayArea
+}
+
+func (r *Reconnecting) Encoder(index int) benjamin.Encoder {
+	if index < 0 || index >= len(r.encoder) {
+		return nil
+	}
+	return r.encoder[index]
+}
+
+func (r *Reconnecting) Encoders() int {
+	return len(r.encoder)
+}
+
+func (r *Reconnecting) Button(index int) benjamin.Button {
+	if index < 0 || index >= len(r.button) || r.button[index] == nil {
+		return nil
+	}
+	return r.button[index]
+}
+
+func (r *Reconnecting) ButtonAt(p image.Point) benjamin.Button {
+	b := r.lastDevice().ButtonAt(p)
+	if b == nil {
+		return nil
+	}
+	return r.Button(b.Index())
+}
+
+func (r *Reconnecting) Buttons() int {
+	return len(r.button)
+}
+
+func (r *Reconnecting) ButtonLayout() image.Point {
+	return r.lastDevice().ButtonLayout()
+}
+
+func (r *Reconnecting) ButtonArea() benjamin.Screen {
+	if r.buttonArea == nil {
+		return nil
+	}
+	return r.buttonArea
+}
+
+// Rotation of the last connected device.
+func (r *Reconnecting) Rotation() benjamin.Rotation {
+	if d, ok := r.lastDevice().(benjamin.Rotatable); ok {
+		return d.Rotation()
+	}
+	return benjamin.Rotate0
+}
+
+// SetRotation sets the rotation the device is mounted with, it is kept when the
+// device reconnects. Devices that can't be rotated ignore it.
+func (r *Reconnecting) SetRotation(rotation benjamin.Rotation) {
+	if d, ok := r.lastDevice().(benjamin.Rotatable); ok {
+		d.SetRotation(rotation)
+	}
+}
+
+// Snapshot of the last connected device, if it supports snapshots.
+func (r *Reconnecting) Snapshot() (image.Image, error) {
+	if s, ok := r.lastDevice().(benjamin.Snapshotter); ok {
+		return s.Snapshot()
+	}
+	return nil, benjamin.ErrNotSupported
+}
+
+func (r *Reconnecting) closed() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// run forwards events from the connected device, and reconnects when the
+// device goes away.
+func (r *Reconnecting) run(device benjamin.Device) {
+	defer close(r.events)
+
+	if !r.emit(benjamin.NewDeviceAttach(r)) {
+		return
+	}
+	for {
+		var reason error
+		for event := range device.Events() {
+			if event.Type == benjamin.TypeError {
+				reason = event.Data.(benjamin.Error).Error
+			}
+			if !r.emit(event.Translate(r, r.peripheralFunc(device))) {
+				return
+			}
+		}
+		if r.closed() {
+			return
+		}
+
+		_ = device.Close()
+		r.mu.Lock()
+		r.device = nil
+		r.mu.Unlock()
+		if !r.emit(benjamin.NewDeviceDetach(r, reason)) {
+			return
+		}
+
+		detached := time.Now()
+		if device = r.reconnect(); device == nil {
+			return
+		}
+		if !r.emit(benjamin.NewDeviceReconnect(r, time.Since(detached))) {
+			return
+		}
+	}
+}
+
+func (r *Reconnecting) emit(event benjamin.Event) bool {
+	select {
+	case r.events <- event:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// reconnect waits for the device to reappear, and restores its state. Returns
+// nil if the device was closed.
+func (r *Reconnecting) reconnect() benjamin.Device {
+	key := r.id.Key()
+
+	// A new Watch reports the device as attached with a new device, also if
+	// the watcher did not notice the device was gone. Other watchers are not
+	// told about the lost connection, their devices may still work.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		events   = WatchWith(ctx, r.open)
+		attached benjamin.Device // attached device that is not opened yet
+		t        = time.NewTicker(WatchInterval)
+	)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.done:
+			return nil
+		case event := <-events:
+			if event.ID.Key() != key {
+				continue
+			}
+			if event.Type == Detach {
+				attached = nil
+				continue
+			}
+			attached = event.Device
+		case <-t.C:
+		}
+		if attached == nil {
+			continue
+		}
+		if err := attached.Open(); err != nil {
+			// Maybe not accessible yet, try again later.
+			continue
+		}
+
+		device := attached
+		r.mu.Lock()
+		previous := r.last
+		r.device = device
+		r.last = device
+		r.mu.Unlock()
+		r.restore(device, previous)
+		return device
+	}
+}
+
+// settingsCopier is a device with settings besides the brightness, such as
+// the image encoding of a Stream Deck.
+type settingsCopier interface {
+	// CopySettings applies the settings of from, the device it replaces.
+	CopySettings(from benjamin.Device)
+}
+
+// restore settings, brightness and images on a reconnected device, that
+// replaces previous.
+func (r *Reconnecting) restore(device, previous benjamin.Device) {
+	// Settings go first, they can change the geometry of the images.
+	if c, ok := device.(settingsCopier); ok {
+		c.CopySettings(previous)
+	} else if p, ok := previous.(benjamin.Rotatable); ok {
+		if d, ok := device.(benjamin.Rotatable); ok {
+			d.SetRotation(p.Rotation())
+		}
+	}
+
+	// Take the images under the lock, keep replaces them with new images.
+	type replay struct {
+		seq    uint64
+		image  image.Image
+		target func(benjamin.Device) benjamin.Drawable
+	}
+	r.mu.Lock()
+	var (
+		bright     = r.bright
+		brightness = r.brightness
+		replays    []replay
+	)
+	for _, s := range r.shadows() {
+		if s.image != nil {
+			replays = append(replays, replay{s.seq, s.image, s.target})
+		}
+	}
+	r.mu.Unlock()
+
+	if bright {
+		_ = device.SetBrightness(brightness)
+	}
+
+	// Areas overlap with the individual peripherals, so replay all images in
+	// the order they were originally set.
+	sort.Slice(replays, func(i, j int) bool { return replays[i].seq < replays[j].seq })
+	for _, p := range replays {
+		if target := p.target(device); target != nil {
+			_ = target.SetImage(p.image)
+		}
+	}
+}
+
+// shadows returns all drawables, the caller must hold the lock.
+func (r *Reconnecting) shadows() []*shadow {
+	var shadows []*shadow
+	for _, b := range r.button {
+		if b != nil {
+			shadows = append(shadows, &b.shadow)
+		}
+	}
+	for _, d := range r.display {
+		if d != nil {
+			shadows = append(shadows, &d.shadow)
+		}
+	}
+	for _, s := range []*reconnectingScreen{r.buttonArea, r.displayArea} {
+		if s != nil {
+			shadows = append(shadows, &s.shadow)
+		}
+	}
+	return shadows
+}
+
+// peripheralFunc maps peripherals of device to our peripherals.
+func (r *Reconnecting) peripheralFunc(device benjamin.Device) func(benjamin.Peripheral) benjamin.Peripheral {
+	return func(p benjamin.Peripheral) benjamin.Peripheral {
+		i := p.Index()
+		switch {
+		case i >= 0 && i < len(r.button) && device.Button(i) == p:
+			return r.button[i]
+		case i >= 0 && i < len(r.display) && device.Display(i) == p:
+			return r.display[i]
+		case i >= 0 && i < len(r.encoder) && device.Encoder(i) == p:
+			return r.encoder[i]
+		case r.buttonArea != nil && device.ButtonArea() == p:
+			return r.buttonArea
+		case r.displayArea != nil && device.DisplayArea() == p:
+			return r.displayArea
+		default:
+			return p
+		}
+	}
+}
+
+// shadow keeps the last image set on a drawable.
+type shadow struct {
+	device *Reconnecting
+	index  int
+	size   image.Point
+	target func(benjamin.Device) benjamin.Drawable
+	seq    uint64
+	image  image.Image
+}
+
+func newShadow(device *Reconnecting, index int, size image.Point, target func(benjamin.Device) benjamin.Drawable) shadow {
+	return shadow{
+		device: device,
+		index:  index,
+		size:   size,
+		target: target,
+	}
+}
+
+func (s *shadow) Capabilities() benjamin.PeripheralCapabilities {
+	if target, ok := s.target(s.device.lastDevice()).(benjamin.Peripheral); ok {
+		return benjamin.PeripheralCapabilitiesOf(target)
+	}
+	return benjamin.PeripheralCapabilities{Screen: benjamin.HasScreen(s), Size: s.size}
+}
+
+func (s *shadow) Surface() benjamin.Surface { return s.device }
+func (s *shadow) Index() int                { return s.index }
+
+// Size of the drawable on the last connected device, it changes with the
+// rotation of the device.
+func (s *shadow) Size() image.Point {
+	if target := s.target(s.device.lastDevice()); target != nil {
+		return target.Size()
+	}
+	return s.size
+}
+
+func (s *shadow) SetImage(i image.Image) error {
+	if !benjamin.HasScreen(s) {
+		return benjamin.ErrNotSupported
+	}
+	s.keep(i)
+	if device := s.device.Connected(); device != nil {
+		if target := s.target(device); target != nil {
+			return target.SetImage(i)
+		}
+	}
+	return nil
+}
+
+// keep a copy of the image, so it can be replayed after reconnecting.
+func (s *shadow) keep(i image.Image) {
+	s.device.mu.Lock()
+	defer s.device.mu.Unlock()
+
+	s.device.seq++
+	s.seq = s.device.seq
+	switch i := i.(type) {
+	case nil:
+		// Replay a blank, not whatever the device shows after reconnecting.
+		s.image = image.Black
+	case *image.Uniform:
+		s.image = i
+	default:
+		// Copy to a new image, restore may still be drawing the previous one.
+		b := i.Bounds()
+		o := image.NewNRGBA(image.Rectangle{Max: b.Size()})
+		draw.Copy(o, image.Point{}, i, b, draw.Src, nil)
+		s.image = o
+	}
+}
+
+type reconnectingButton struct {
+	shadow
+	pos image.Point
+}
+
+func (b *reconnectingButton) Position() image.Point {
+	if p := b.device.lastDevice().Button(b.index); p != nil {
+		return p.Position()
+	}
+	return b.pos
+}
+
+type reconnectingDisplay struct {
+	shadow
+}
+
+type reconnectingScreen struct {
+	shadow
+}
+
+type reconnectingEncoder struct {
+	device  *Reconnecting
+	index   int
+	display *reconnectingDisplay
+}
+
+func (e *reconnectingEncoder) Capabilities() benjamin.PeripheralCapabilities {
+	if p := e.device.lastDevice().Encoder(e.index); p != nil {
+		return benjamin.PeripheralCapabilitiesOf(p)
+	}
+	return benjamin.PeripheralCapabilities{Push: true}
+}
+
+func (e *reconnectingEncoder) Surface() benjamin.Surface { return e.device }
+func (e *reconnectingEncoder) Index() int                { return e.index }
+
+func (e *reconnectingEncoder) Display() benjamin.Display {
+	if e.display == nil {
+		return nil
+	}
+	return e.display
+}
+
+var (
+	_ benjamin.Device      = (*Reconnecting)(nil)
+	_ benjamin.USBDevice   = (*Reconnecting)(nil)
+	_ benjamin.Snapshotter = (*Reconnecting)(nil)
+	_ benjamin.Rotatable   = (*Reconnecting)(nil)
+	_ benjamin.Button      = (*reconnectingButton)(nil)
+	_ benjamin.Display     = (*reconnectingDisplay)(nil)
+	_ benjamin.Screen      = (*reconnectingScreen)(nil)
+	_ benjamin.Encoder     = (*reconnectingEncoder)(nil)
+)
@@ -0,0 +1,159 @@
+package driver_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/karalabe/hid"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver"
+	"github.com/tehmaze/benjamin/driver/mock"
+	"github.com/tehmaze/benjamin/driver/streamdeck"
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+type reconnectDevice struct {
+	*mock.Mock
+}
+
+func (reconnectDevice) Serial() string { return "reconnect" }
+
+func TestReconnect(t *testing.T) {
+	var (
+		present = make(chan *mock.Mock, 1)
+		next    *mock.Mock
+	)
+	driver.RegisterForTest(t, func() bool {
+		select {
+		case next = <-present:
+			return true
+		default:
+			return false
+		}
+	}, func() benjamin.Device {
+		return reconnectDevice{next}
+	})
+	driver.SetWatchIntervalForTest(t, time.Millisecond)
+
+	var (
+		first = mock.New().(*mock.Mock)
+		r     = driver.Reconnect(reconnectDevice{first})
+		white = image.NewUniform(color.White)
+	)
+	if err := r.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if err := r.SetBrightness(0.3); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Button(2).SetImage(white); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Button(3).SetImage(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if event := <-r.Events(); event.Type != benjamin.TypeDeviceAttach {
+		t.Fatalf("expected device attach, got %s", event)
+	}
+
+	first.Press(2)
+	if event := <-r.Events(); event.Type != benjamin.TypeButtonPress || event.Peripheral != r.Button(2) {
+		t.Fatalf("expected press on button 2, got %s", event)
+	}
+
+	first.Fail(errors.New("gone"))
+	_ = first.Close()
+	for _, want := range []benjamin.EventType{benjamin.TypeError, benjamin.TypeDeviceDetach} {
+		if event := <-r.Events(); event.Type != want {
+			t.Fatalf("expected %s, got %s", want, event.Type)
+		}
+	}
+
+	second := mock.New().(*mock.Mock)
+	present <- second
+	select {
+	case event := <-r.Events():
+		if event.Type != benjamin.TypeDeviceReconnect {
+			t.Fatalf("expected device reconnect, got %s", event.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for reconnect")
+	}
+
+	if b := second.Brightness(); len(b) != 1 || b[0] != 0.3 {
+		t.Errorf("expected brightness to be restored, got %v", b)
+	}
+	if i := second.Button(2).(*mock.Button).Image(); i == nil || i.NRGBAAt(0, 0) != (color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+		t.Error("expected button image to be restored")
+	}
+	if i := second.Button(3).(*mock.Button).Image(); i == nil || i.NRGBAAt(0, 0) != (color.NRGBA{A: 0xff}) {
+		t.Error("expected cleared button to be restored blank")
+	}
+}
+
+func TestReconnectSettings(t *testing.T) {
+	var (
+		info    = hid.DeviceInfo{VendorID: streamdeck.VendorID, ProductID: streamdeck.Plus.ProductID, Serial: "reconnect-settings"}
+		present = make(chan *streamdeck.Device, 1)
+		next    *streamdeck.Device
+	)
+	driver.RegisterForTest(t, func() bool {
+		select {
+		case next = <-present:
+			return true
+		default:
+			return false
+		}
+	}, func() benjamin.Device {
+		return next
+	})
+	driver.SetWatchIntervalForTest(t, time.Millisecond)
+
+	var (
+		m     = transport.NewMemory()
+		first = streamdeck.NewWithTransport(info, streamdeck.Plus, m)
+		r     = driver.Reconnect(first)
+	)
+	first.SetBezel(true)
+	first.SetEncoding(streamdeck.Encoding{Quality: 50})
+	if err := r.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.SetRotation(benjamin.Rotate90)
+
+	_ = m.Close()
+	for event := range r.Events() {
+		if event.Type == benjamin.TypeDeviceDetach {
+			break
+		}
+	}
+
+	second := streamdeck.NewWithTransport(info, streamdeck.Plus, transport.NewMemory())
+	present <- second
+	for event := range r.Events() {
+		if event.Type == benjamin.TypeDeviceReconnect {
+			break
+		}
+	}
+
+	if rotation := second.Rotation(); rotation != benjamin.Rotate90 {
+		t.Errorf("expected rotation to be restored, got %s", rotation)
+	}
+	if !second.Bezel() {
+		t.Error("expected bezel to be restored")
+	}
+	if q := second.Encoding().Quality; q != 50 {
+		t.Errorf("expected encoding to be restored, got quality %d", q)
+	}
+	if l := r.ButtonLayout(); !l.Eq(image.Pt(2, 4)) {
+		t.Errorf("expected rotated 2x4 layout, got %s", l)
+	}
+}
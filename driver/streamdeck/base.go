@@ -1,15 +1,19 @@
 package streamdeck
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/karalabe/hid"
 
 	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/transport"
+	"github.com/tehmaze/benjamin/internal/imageutil"
 )
 
 // VendorID for Elgate (Corsair) Stream Decks
@@ -26,32 +30,143 @@ type Properties struct {
 	displays            int         //
 	displayLayout       image.Point // in cols x rows
 	displaySize         image.Point // in pixels
+	displayTransform    imageTransform
+	displayTouch        bool
+	displayRegions      bool        // display supports partial updates
 	encoders            int         //
 	keys                int         //
 	keyLayout           image.Point // in cols x rows
 	keySize             image.Point // in pixels
+	keyGap              image.Point // physical key pitch minus key size, in pixels at key image scale
+	touchKeys           int         // keys without display, following the keys
 	keyDataOffset       int
 	keyTranslate        func(int) int
 	keyImageTransform   imageTransform
-	imageBytes          func(*image.NRGBA) ([]byte, error)
+	keyFormat           benjamin.ImageFormat // defaults to JPEG
 	imagePageSize       int
 	imagePageHeaderSize int
 }
 
-func driverFor(p Properties) func(hid.DeviceInfo) benjamin.Device {
-	return func(info hid.DeviceInfo) benjamin.Device {
-		return New(info, p)
+// Buttons is the number of buttons, including buttons without display.
+func (p Properties) Buttons() int { return p.keys + p.touchKeys }
+
+// ButtonLayout is the button layout in columns and rows.
+func (p Properties) ButtonLayout() image.Point { return p.keyLayout }
+
+// ButtonSize is the button image size in pixels.
+func (p Properties) ButtonSize() image.Point { return p.keySize }
+
+// ButtonGap is the approximate physical gap between buttons, in pixels at the
+// scale of the button images.
+func (p Properties) ButtonGap() image.Point { return p.keyGap }
+
+// Displays is the number of displays.
+func (p Properties) Displays() int { return p.displays }
+
+// DisplayLayout is the display layout in columns and rows.
+func (p Properties) DisplayLayout() image.Point { return p.displayLayout }
+
+// DisplaySize is the display image size in pixels.
+func (p Properties) DisplaySize() image.Point { return p.displaySize }
+
+// Encoders is the number of rotary encoders.
+func (p Properties) Encoders() int { return p.encoders }
+
+// Capabilities of the model.
+func (p Properties) Capabilities() benjamin.Capabilities {
+	c := benjamin.Capabilities{
+		Buttons:  make([]benjamin.PeripheralCapabilities, p.Buttons()),
+		Displays: make([]benjamin.PeripheralCapabilities, p.displays),
+		Encoders: make([]benjamin.PeripheralCapabilities, p.encoders),
+
+		// The boot logo can be replaced with a firmware tool, not at runtime.
+		StandbyImage: false,
+	}
+	for i := range c.Buttons {
+		c.Buttons[i] = p.keyCapabilities(i)
+	}
+	for i := range c.Displays {
+		c.Displays[i] = p.displayCapabilities()
+	}
+	for i := range c.Encoders {
+		c.Encoders[i] = benjamin.PeripheralCapabilities{Push: true}
+	}
+	if p.hasKeyImages() || p.displays > 0 {
+		// Devices without screens have no backlight.
+		c.Brightness = benjamin.BrightnessRange{Min: 0, Max: 1, Steps: 101}
+	}
+	return c
+}
+
+func (p Properties) keyCapabilities(index int) benjamin.PeripheralCapabilities {
+	switch {
+	case index >= p.keys:
+		return benjamin.PeripheralCapabilities{Touch: true}
+	case !p.hasKeyImages():
+		return benjamin.PeripheralCapabilities{Push: true}
+	default:
+		return benjamin.PeripheralCapabilities{
+			Screen: true,
+			Size:   p.keySize,
+			Format: p.keyImageFormat(),
+			Push:   true,
+		}
+	}
+}
+
+func (p Properties) displayCapabilities() benjamin.PeripheralCapabilities {
+	return benjamin.PeripheralCapabilities{
+		Screen: true,
+		Size:   p.displaySize,
+		Format: benjamin.FormatJPEG,
+		Touch:  p.displayTouch,
+	}
+}
+
+func (p Properties) keyImageFormat() benjamin.ImageFormat {
+	if p.keyFormat == benjamin.FormatUnknown {
+		return benjamin.FormatJPEG
 	}
+	return p.keyFormat
 }
 
+func (p Properties) hasKeyImages() bool {
+	return p.keys > 0 && !p.keySize.Eq(image.Point{})
+}
+
+func driverFor(p Properties) func(hid.DeviceInfo, transport.Opener) benjamin.Device {
+	return func(info hid.DeviceInfo, open transport.Opener) benjamin.Device {
+		return NewWithOpener(info, p, open)
+	}
+}
+
+// New Stream Deck device, communicating through the host HID library.
 func New(info hid.DeviceInfo, prop Properties) *Device {
+	return newDevice(info, prop, transport.Open)
+}
+
+// NewWithOpener is like New, but opens the device with the supplied Opener.
+func NewWithOpener(info hid.DeviceInfo, prop Properties, open transport.Opener) *Device {
+	return newDevice(info, prop, open)
+}
+
+// NewWithTransport is like New, but communicates through the supplied Transport.
+func NewWithTransport(info hid.DeviceInfo, prop Properties, t transport.Transport) *Device {
+	d := newDevice(info, prop, nil)
+	d.dev = t
+	return d
+}
+
+func newDevice(info hid.DeviceInfo, prop Properties, open transport.Opener) *Device {
 	d := &Device{
-		prop:         prop,
-		info:         info,
-		display:      make([]*display, prop.displays),
-		displayImage: image.NewNRGBA(image.Rect(0, 0, prop.displaySize.X*prop.displays, prop.displaySize.Y)),
-		encoder:      make([]*encoder, prop.encoders),
-		key:          make([]*key, prop.keys),
+		prop:        prop,
+		info:        info,
+		open:        open,
+		display:     make([]*display, prop.displays),
+		encoder:     make([]*encoder, prop.encoders),
+		key:         make([]*key, prop.keys+prop.touchKeys),
+		writeErrors: make(chan error, sendBufferSize),
+		sent:        make(map[writeTarget]uint64),
 	}
 	d.model = prop.model(d)
 
@@ -65,7 +180,7 @@ func New(info hid.DeviceInfo, prop Properties) *Device {
 		d.key[i] = newButton(d, i)
 	}
 
-	if prop.keys > 0 {
+	if prop.hasKeyImages() {
 		d.keyArea = newKeyArea(d)
 	}
 	if prop.displays > 0 {
@@ -77,36 +192,298 @@ func New(info hid.DeviceInfo, prop Properties) *Device {
 
 type Device struct {
 	model
-	prop         Properties
-	info         hid.DeviceInfo
-	mu           sync.Mutex
-	dev          *hid.Device
-	display      []*display
-	displayImage *image.NRGBA
-	displayArea  *displayArea
-	encoder      []*encoder
-	key          []*key
-	keyArea      *keyArea
+	prop          Properties
+	info          hid.DeviceInfo
+	mu            sync.Mutex
+	open          transport.Opener
+	dev           transport.Transport
+	rotation      benjamin.Rotation
+	bezel         bool
+	encoding      Encoding
+	display       []*display
+	displayBuffer *image.NRGBA
+	displayFrame  *image.NRGBA
+	stripMu       sync.Mutex
+	strip         *compositor
+	displayArea   *displayArea
+	encoder       []*encoder
+	key           []*key
+	keyArea       *keyArea
+	writer        *writer
+	async         bool // asynchronous writes were enabled, kept after Close
+	writeErrors   chan error
+	eventsMu      sync.Mutex
+	listeners     []chan benjamin.Event // Events channels, while reading
+	sentMu        sync.Mutex
+	sent          map[writeTarget]uint64
+	page          []byte // page buffer, guarded by mu
 }
 
 func (d *Device) DeviceInfo() hid.DeviceInfo { return d.info }
 func (d *Device) Path() string               { return d.info.Path }
 func (d *Device) Manufacturer() string       { return d.info.Manufacturer }
+func (d *Device) Model() string              { return d.prop.Model }
 func (d *Device) Product() string            { return d.info.Product }
 func (d *Device) Serial() string             { return d.info.Serial }
 
 func (d *Device) Open() error {
 	var err error
 	if d.dev == nil {
-		d.dev, err = d.info.Open()
+		if d.open == nil {
+			return io.ErrClosedPipe
+		}
+		d.dev, err = d.open(d.info)
 	}
 	return err
 }
 
+// Close the device, if asynchronous writes are enabled, queued frames are
+// written first.
 func (d *Device) Close() error {
+	if d.writer != nil {
+		_ = d.writer.close()
+		d.writer = nil
+	}
 	return d.dev.Close()
 }
 
+// SetAsync enables or disables asynchronous writes. When enabled, images are
+// encoded on the calling goroutine and written by a background writer, a frame
+// that is still queued when a new image is set on the same key or display is
+// dropped. Write errors are reported as TypeError events and by Flush.
+//
+// Disabling asynchronous writes waits for all queued frames to be written. This
+// should be done before drawing.
+func (d *Device) SetAsync(enable bool) error {
+	d.async = enable
+	switch {
+	case enable && d.writer == nil:
+		d.writer = newWriter(d.writeErrors)
+	case !enable && d.writer != nil:
+		err := d.writer.close()
+		d.writer = nil
+		return err
+	}
+	return nil
+}
+
+// Flush waits for all queued frames to be written, and returns the first write
+// error since the previous Flush. Flush is a no-op if asynchronous writes are
+// disabled.
+func (d *Device) Flush() error {
+	if d.writer == nil {
+		return nil
+	}
+	return d.writer.flush()
+}
+
+// Invalidate forgets which frames were sent, so the next image set on every key
+// and display is sent even if it is unchanged. This should be used if the
+// device may show something else than what was sent, for example after it was
+// reconnected.
+func (d *Device) Invalidate() {
+	d.sentMu.Lock()
+	d.sent = make(map[writeTarget]uint64)
+	d.sentMu.Unlock()
+}
+
+// unchanged returns whether the frame with the supplied sum was the last frame
+// sent to target, if not, the sum is recorded.
+func (d *Device) unchanged(target writeTarget, sum uint64) bool {
+	d.sentMu.Lock()
+	defer d.sentMu.Unlock()
+	if last, ok := d.sent[target]; ok && last == sum {
+		return true
+	}
+	for t := range d.sent {
+		if t != target && t.overlaps(target) {
+			// Partially overwritten.
+			delete(d.sent, t)
+		}
+	}
+	d.sent[target] = sum
+	return false
+}
+
+func (d *Device) forget(target writeTarget) {
+	d.sentMu.Lock()
+	delete(d.sent, target)
+	d.sentMu.Unlock()
+}
+
+// frameBuffer holds an encoded frame, see frameBuffers.
+type frameBuffer struct {
+	b []byte
+}
+
+// frameBuffers holds encoded frame buffers for reuse.
+var frameBuffers = sync.Pool{New: func() any { return new(frameBuffer) }}
+
+// send encodes a frame in format and sends it to target, through the
+// background writer if enabled. The frame is not sent if it's the same as the
+// last frame sent to target. The frame may be reused after send returns.
+func (d *Device) send(target writeTarget, frame *image.NRGBA, format benjamin.ImageFormat) error {
+	buf, err := d.encode(target, frame, format)
+	if err != nil {
+		return err
+	}
+	return d.deliver(target, buf)
+}
+
+// encode a frame for target in format, returns nil if the frame is the same as
+// the last frame sent to target. The encoded frame must be passed to deliver or
+// discard. It's safe to encode frames for different targets concurrently.
+func (d *Device) encode(target writeTarget, frame *image.NRGBA, format benjamin.ImageFormat) (*frameBuffer, error) {
+	if d.unchanged(target, imageutil.SumNRGBA(frame)) {
+		return nil, nil
+	}
+
+	var (
+		buf = frameBuffers.Get().(*frameBuffer)
+		err error
+	)
+	if buf.b, err = d.encoding.encode(buf.b[:0], frame, format); err != nil {
+		frameBuffers.Put(buf)
+		d.forget(target)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// discard encoded frames that will not be delivered.
+func (d *Device) discard(targets []writeTarget, bufs []*frameBuffer) {
+	for i, buf := range bufs {
+		if buf != nil {
+			frameBuffers.Put(buf)
+			d.forget(targets[i])
+		}
+	}
+}
+
+// deliver an encoded frame to target, through the background writer if
+// enabled. Frames are written in the order they are delivered.
+func (d *Device) deliver(target writeTarget, buf *frameBuffer) error {
+	if buf == nil {
+		// Unchanged.
+		return nil
+	}
+	if d.writer == nil {
+		err := d.writeFrame(target, buf.b)
+		frameBuffers.Put(buf)
+		return err
+	}
+	d.writer.queue(target, func() error {
+		// Frames dropped by the writer leave their buffer to the collector.
+		defer frameBuffers.Put(buf)
+		return d.writeFrame(target, buf.b)
+	})
+	return nil
+}
+
+// writeFrame writes an encoded frame to target.
+func (d *Device) writeFrame(target writeTarget, b []byte) error {
+	var err error
+	if target.display {
+		err = d.SetDisplayImage(target.rect, b)
+	} else {
+		err = d.SetButtonImage(target.index, b)
+	}
+	if err != nil {
+		// Unknown what the device shows now.
+		d.forget(target)
+	}
+	return err
+}
+
+func (d *Device) Capabilities() benjamin.Capabilities {
+	c := d.prop.Capabilities()
+	for i := range c.Displays {
+		c.Displays[i].Size = d.rotation.Size(c.Displays[i].Size)
+	}
+	return c
+}
+
+// Rotation returns the rotation the device is mounted with.
+func (d *Device) Rotation() benjamin.Rotation {
+	return d.rotation
+}
+
+// SetRotation sets the clockwise rotation the device is mounted with. Button
+// positions, layouts, touch coordinates and images are remapped, so they are in
+// the orientation as mounted.
+func (d *Device) SetRotation(r benjamin.Rotation) {
+	d.rotation = r & 3
+}
+
+// Bezel returns whether the button area models the gaps between the keys.
+func (d *Device) Bezel() bool {
+	return d.bezel
+}
+
+// SetBezel enables or disables modelling the physical gaps between the keys in
+// the button area. When enabled, the size of the button area includes the gaps,
+// and images set on it are sampled as if seen through the bezel; the pixels
+// that fall on the gaps are not shown.
+func (d *Device) SetBezel(enable bool) {
+	d.bezel = enable
+}
+
+// Encoding returns the image encoding options of the device.
+func (d *Device) Encoding() Encoding {
+	return d.encoding
+}
+
+// SetEncoding sets the image encoding options of the device. Frames that are
+// unchanged are not sent again, use Invalidate to resend them with the new
+// options.
+func (d *Device) SetEncoding(e Encoding) {
+	d.encoding = e
+}
+
+// CopySettings applies the rotation, bezel, encoding and asynchronous writes of
+// from to the device, if from is a Stream Deck. It is used by
+// driver.Reconnecting to restore the settings of a device that is plugged back
+// in, so from may be closed already.
+func (d *Device) CopySettings(from benjamin.Device) {
+	o, ok := from.(*Device)
+	if !ok || o == d {
+		return
+	}
+	d.rotation, d.bezel, d.encoding = o.rotation, o.bezel, o.encoding
+	if o.async {
+		_ = d.SetAsync(true)
+	}
+}
+
+// ButtonAreaSize returns the size of the button area, optionally including the
+// gaps between the keys.
+func (d *Device) ButtonAreaSize(bezel bool) image.Point {
+	var (
+		l = d.ButtonLayout()
+		k = d.prop.keySize
+		s = image.Pt(l.X*k.X, l.Y*k.Y)
+	)
+	if bezel && l.X > 0 && l.Y > 0 {
+		g := d.rotation.Size(d.prop.keyGap)
+		s = s.Add(image.Pt((l.X-1)*g.X, (l.Y-1)*g.Y))
+	}
+	return s
+}
+
+// rotate returns the logical image i in the physical orientation of the device,
+// buf is used to render the rotated image.
+func (d *Device) rotate(buf **image.NRGBA, i *image.NRGBA) *image.NRGBA {
+	if d.rotation == benjamin.Rotate0 {
+		return i
+	}
+	size := d.rotation.Size(i.Rect.Size())
+	if *buf == nil || !(*buf).Rect.Size().Eq(size) {
+		*buf = image.NewNRGBA(image.Rectangle{Max: size})
+	}
+	rotateImage(*buf, i, d.rotation)
+	return *buf
+}
+
 func (d *Device) Display(index int) benjamin.Display {
 	if index < 0 || index >= d.prop.displays {
 		return nil
@@ -119,10 +496,13 @@ func (d *Device) Displays() int {
 }
 
 func (d *Device) DisplayLayout() image.Point {
-	return d.prop.displayLayout
+	return d.rotation.Size(d.prop.displayLayout)
 }
 
 func (d *Device) DisplayArea() benjamin.Screen {
+	if d.displayArea == nil {
+		return nil
+	}
 	return d.displayArea
 }
 
@@ -131,15 +511,24 @@ func (d *Device) Encoders() int {
 }
 
 func (d *Device) ButtonAt(p image.Point) benjamin.Button {
-	return d.Button(p.Y*d.prop.keyLayout.X + p.X)
+	for _, k := range d.key[d.prop.keys:] {
+		if k.Position().Eq(p) {
+			return k
+		}
+	}
+	p = d.rotation.Inverse().Point(p, d.ButtonLayout())
+	if p.In(image.Rectangle{Max: d.prop.keyLayout}) {
+		return d.Button(p.Y*d.prop.keyLayout.X + p.X)
+	}
+	return nil
 }
 
 func (d *Device) Buttons() int {
-	return d.prop.keys
+	return len(d.key)
 }
 
 func (d *Device) ButtonLayout() image.Point {
-	return d.prop.keyLayout
+	return d.rotation.Size(d.prop.keyLayout)
 }
 
 func (d *Device) Encoder(index int) benjamin.Encoder {
@@ -150,43 +539,107 @@ func (d *Device) Encoder(index int) benjamin.Encoder {
 }
 
 func (d *Device) Button(index int) benjamin.Button {
-	if index < 0 || index >= d.prop.keys {
+	if index < 0 || index >= len(d.key) {
 		return nil
 	}
 	return d.key[index]
 }
 
 func (d *Device) ButtonArea() benjamin.Screen {
+	if d.keyArea == nil {
+		return nil
+	}
 	return d.keyArea
 }
 
+// Events returns a channel with the input events and the errors of the
+// background writer. The device is read by a single goroutine, that starts with
+// the first call; every call returns a channel that receives all events, so all
+// channels must be drained. The channels are closed when reading fails, such as
+// when the device is closed.
 func (d *Device) Events() <-chan benjamin.Event {
 	c := make(chan benjamin.Event, 16)
 
-	go func(c chan<- benjamin.Event) {
-		defer close(c)
+	d.eventsMu.Lock()
+	defer d.eventsMu.Unlock()
+	if d.listeners == nil {
+		go d.read()
+	}
+	d.listeners = append(d.listeners, c)
+	return c
+}
+
+// read the device and forward the write errors, until reading fails. Events
+// are sent to all listeners.
+func (d *Device) read() {
+	var (
+		events = make(chan benjamin.Event, 16)
+		stop   = make(chan struct{})
+		done   = make(chan struct{})
+	)
+
+	// Forward errors from the background writer.
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case err := <-d.writeErrors:
+				select {
+				case events <- benjamin.NewError(d, err):
+				case <-stop:
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer func() {
+			close(stop)
+			<-done
+			close(events)
+		}()
 
 		p := make([]byte, 64)
 		for {
 			n, err := d.dev.Read(p)
 			if err != nil {
-				c <- benjamin.NewError(d, err)
+				events <- benjamin.NewError(d, err)
 				return
 			}
-			d.Handle(p[:n], c)
+			d.Handle(p[:n], events)
+		}
+	}()
+
+	for event := range events {
+		d.eventsMu.Lock()
+		listeners := d.listeners
+		d.eventsMu.Unlock()
+		for _, c := range listeners {
+			c <- event
 		}
-	}(c)
+	}
 
-	return c
+	d.eventsMu.Lock()
+	for _, c := range d.listeners {
+		close(c)
+	}
+	d.listeners = nil
+	d.eventsMu.Unlock()
 }
 
 func (d *Device) Clear() error {
-	for _, d := range d.display {
-		if err := d.SetImage(image.Black); err != nil {
+	if d.prop.displays > 0 {
+		if err := d.clearStrip(); err != nil {
 			return err
 		}
 	}
-	for _, k := range d.key {
+	if !d.prop.hasKeyImages() {
+		return nil
+	}
+	for _, k := range d.key[:d.prop.keys] {
 		if err := k.SetImage(image.Black); err != nil {
 			return err
 		}
@@ -205,24 +658,60 @@ func (d *Device) sendFeatureReport(p []byte) error {
 	return err
 }
 
+func (d *Device) getFeatureReport(p []byte) (int, error) {
+	if d.dev == nil {
+		return 0, io.ErrClosedPipe
+	}
+
+	d.mu.Lock()
+	n, err := d.dev.GetFeatureReport(p)
+	d.mu.Unlock()
+	return n, err
+}
+
+// getFeatureString reads a string from the feature report with the given ID,
+// the string starts at offset.
+func (d *Device) getFeatureString(id byte, size, offset int) (string, error) {
+	p := make([]byte, size)
+	p[0] = id
+	n, err := d.getFeatureReport(p)
+	if err != nil {
+		return "", err
+	}
+	if n <= offset {
+		return "", nil
+	}
+	p = p[offset:n]
+	if i := bytes.IndexByte(p, 0); i != -1 {
+		p = p[:i]
+	}
+	return strings.TrimSpace(string(p)), nil
+}
+
 type model interface {
 	Reset() error
 	SetBrightness(float64) error
 	Handle(p []byte, c chan<- benjamin.Event)
 	SetButtonImage(keyIndex int, imageData []byte) error
-	SetDisplayImage(imageData []byte) error
+	SetDisplayImage(area image.Rectangle, imageData []byte) error
+	Firmware() (string, error)
+	DeviceSerial() (string, error)
 }
 
 type baseModel struct {
 	*Device
 	reset               func(*Device) error
 	setBrightness       func(*Device, float64) error
-	imagePageHeader     func(pageIndex, keyIndex, dataSize int, isLast bool) []byte
+	firmware            func(*Device) (string, error)
+	serial              func(*Device) (string, error)
+	imagePageHeader     func(dst []byte, pageIndex, keyIndex, dataSize int, isLast bool) []byte
 	imagePageHeaderSize int
 	imagePageSize       int
 }
 
+// Reset the device, this invalidates all frames sent.
 func (m *baseModel) Reset() error {
+	m.Invalidate()
 	return m.reset(m.Device)
 }
 
@@ -230,6 +719,14 @@ func (m *baseModel) SetBrightness(v float64) error {
 	return m.setBrightness(m.Device, v)
 }
 
+func (m *baseModel) Firmware() (string, error) {
+	return m.firmware(m.Device)
+}
+
+func (m *baseModel) DeviceSerial() (string, error) {
+	return m.serial(m.Device)
+}
+
 func (m *baseModel) Handle(p []byte, c chan<- benjamin.Event) {
 	switch p[1] {
 	case 0x00: // key
@@ -239,13 +736,16 @@ func (m *baseModel) Handle(p []byte, c chan<- benjamin.Event) {
 
 func (m *baseModel) handleButton(p []byte, c chan<- benjamin.Event) {
 	state := p[m.prop.keyDataOffset:]
-	for i := 0; i < len(state) && i < m.prop.keys; i++ {
+	for i := 0; i < len(state) && i < m.prop.keys+m.prop.touchKeys; i++ {
 		var (
 			press = state[i] != 0
-			index = m.prop.keyTranslate(i)
-			key   = m.key[index]
+			index = i
 		)
-		if key.state != state[index] {
+		if i < m.prop.keys {
+			index = m.prop.keyTranslate(i)
+		}
+		key := m.key[index]
+		if key.state != state[i] {
 			key.state = state[i]
 			if press {
 				key.press = time.Now()
@@ -258,60 +758,83 @@ func (m *baseModel) handleButton(p []byte, c chan<- benjamin.Event) {
 }
 
 func (m *baseModel) SetButtonImage(index int, imageBytes []byte) error {
-	var (
-		data = imageData{
-			Data:     imageBytes,
-			PageSize: m.prop.imagePageSize - m.prop.imagePageHeaderSize,
-		}
-		buf       = make([]byte, m.prop.imagePageSize)
-		header, b []byte
-		last      bool
-		err       error
-	)
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	for page := 0; !last; page++ {
-		b, last = data.Page(page)
-		header = m.imagePageHeader(page, index, len(b), last)
-		copy(buf, header)
-		copy(buf[len(header):], b)
-		if _, err = m.dev.Write(buf); err != nil {
-			return fmt.Errorf("streamdeck: image transfer to key %d failed: %w", index, err)
-		}
+	err := m.writePages(imageBytes, m.prop.imagePageSize, m.prop.imagePageHeaderSize, func(dst []byte, page, size int, last bool) []byte {
+		return m.imagePageHeader(dst, page, index, size, last)
+	})
+	if err != nil {
+		return fmt.Errorf("streamdeck: image transfer to key %d failed: %w", index, err)
 	}
 	return nil
 }
 
-func (m *baseModel) SetDisplayImage(imageBytes []byte) error {
+func (m *baseModel) SetDisplayImage(area image.Rectangle, imageBytes []byte) error {
 	const (
 		displayPageSize       = 1024
 		displayPageHeaderSize = 16
 	)
+	err := m.writePages(imageBytes, displayPageSize, displayPageHeaderSize, func(dst []byte, page, size int, last bool) []byte {
+		return m.displayPageHeader(dst, page, area, size, last)
+	})
+	if err != nil {
+		return fmt.Errorf("streamdeck: image transfer to display failed: %w", err)
+	}
+	return nil
+}
+
+// displayRegion returns the target and frame for the region r of the display
+// image i, in physical coordinates. If the display doesn't support partial
+// updates, the frame is the whole image. The frame is nil if r is empty.
+func (d *Device) displayRegion(i *image.NRGBA, r image.Rectangle) (writeTarget, *image.NRGBA) {
+	if !d.prop.displayRegions || d.prop.displayTransform != nil {
+		r = i.Rect
+	}
+	if r = r.Intersect(i.Rect); r.Empty() {
+		return writeTarget{}, nil
+	}
+
+	if d.prop.displayTransform != nil {
+		if d.displayBuffer == nil || !d.displayBuffer.Rect.Eq(i.Rect) {
+			d.displayBuffer = image.NewNRGBA(i.Rect)
+		}
+		copy(d.displayBuffer.Pix, i.Pix)
+		d.prop.displayTransform.Transform(d.displayBuffer)
+		i = d.displayBuffer
+	}
+
+	return writeTarget{display: true, rect: r}, i.SubImage(r).(*image.NRGBA)
+}
+
+// writePages sends the image data in pages of pageSize bytes, each page starts
+// with the header appended by the header function.
+func (d *Device) writePages(imageBytes []byte, pageSize, headerSize int, header func(dst []byte, page, size int, last bool) []byte) error {
 	var (
 		data = imageData{
 			Data:     imageBytes,
-			PageSize: displayPageSize - displayPageHeaderSize,
+			PageSize: pageSize - headerSize,
 		}
-		buf       = make([]byte, displayPageSize)
-		header, b []byte
-		last      bool
-		err       error
+		b    []byte
+		last bool
 	)
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cap(d.page) < pageSize {
+		d.page = make([]byte, pageSize)
+	}
+	buf := d.page[:pageSize]
 	for page := 0; !last; page++ {
 		b, last = data.Page(page)
-		header = m.displayPageHeader(page, m.displayImage.Rect, len(b), last)
-		copy(buf, header)
-		copy(buf[len(header):], b)
-		if _, err = m.dev.Write(buf); err != nil {
-			return fmt.Errorf("streamdeck: image transfer to display failed: %w", err)
+		n := len(append(header(buf[:0], page, len(b), last), b...))
+		for i := n; i < len(buf); i++ {
+			buf[i] = 0
+		}
+		if _, err := d.dev.Write(buf); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func (m *baseModel) displayPageHeader(pageIndex int, area image.Rectangle, dataSize int, isLast bool) []byte {
+func (m *baseModel) displayPageHeader(dst []byte, pageIndex int, area image.Rectangle, dataSize int, isLast bool) []byte {
 	var last byte
 	if isLast {
 		last = 0x01
@@ -322,20 +845,30 @@ func (m *baseModel) displayPageHeader(pageIndex int, area image.Rectangle, dataS
 		w = uint16(area.Dx())
 		h = uint16(area.Dy())
 	)
-	return []byte{
+	return append(dst,
 		0x02, 0x0c,
-		byte(x), byte(x >> 8),
-		byte(y), byte(y >> 8),
-		byte(w), byte(w >> 8),
-		byte(h), byte(h >> 8),
+		byte(x), byte(x>>8),
+		byte(y), byte(y>>8),
+		byte(w), byte(w>>8),
+		byte(h), byte(h>>8),
 		last,
 		byte(pageIndex),
-		byte(pageIndex >> 8),
+		byte(pageIndex>>8),
 		byte(dataSize),
-		byte(dataSize >> 8),
+		byte(dataSize>>8),
 		0x00,
-	}
+	)
 }
 
 func translateLTR() func(int) int      { return func(i int) int { return i } }
 func translateRTL(o int) func(int) int { return func(i int) int { return o - i - 1 } }
+
+var (
+	_ benjamin.Device             = (*Device)(nil)
+	_ benjamin.USBDevice          = (*Device)(nil)
+	_ benjamin.FirmwareDevice     = (*Device)(nil)
+	_ benjamin.CapabilityReporter = (*Device)(nil)
+	_ benjamin.Rotatable          = (*Device)(nil)
+	_ benjamin.BatchCommitter     = (*Device)(nil)
+	_ benjamin.Snapshotter        = (*Device)(nil)
+)
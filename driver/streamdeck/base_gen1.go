@@ -31,18 +31,26 @@ func gen1SetBrightness(d *Device, v float64) error {
 	})
 }
 
-func gen1ImagePageHeader(pageIndex, keyIndex, dataSize int, isLast bool) []byte {
+func gen1Firmware(d *Device) (string, error) {
+	return d.getFeatureString(0x04, 17, 5)
+}
+
+func gen1Serial(d *Device) (string, error) {
+	return d.getFeatureString(0x03, 17, 5)
+}
+
+func gen1ImagePageHeader(dst []byte, pageIndex, keyIndex, dataSize int, isLast bool) []byte {
 	var last byte
 	if isLast {
 		last = 0x01
 	}
-	return []byte{
+	return append(dst,
 		0x02, 0x01,
-		byte(pageIndex + 1), 0x00,
+		byte(pageIndex+1), 0x00,
 		last,
-		byte(keyIndex + 1),
+		byte(keyIndex+1),
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-	}
+	)
 }
 
 func gen1(device *Device) model {
@@ -50,6 +58,8 @@ func gen1(device *Device) model {
 		Device:              device,
 		reset:               gen1Reset,
 		setBrightness:       gen1SetBrightness,
+		firmware:            gen1Firmware,
+		serial:              gen1Serial,
 		imagePageHeader:     gen1ImagePageHeader,
 		imagePageHeaderSize: gen1ImagePageHeaderSize,
 		imagePageSize:       gen1ImagePageSize,
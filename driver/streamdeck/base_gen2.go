@@ -31,20 +31,28 @@ func gen2SetBrightness(d *Device, v float64) error {
 	})
 }
 
-func gen2ImagePageHeader(pageIndex, keyIndex, dataSize int, isLast bool) []byte {
+func gen2Firmware(d *Device) (string, error) {
+	return d.getFeatureString(0x05, 32, 6)
+}
+
+func gen2Serial(d *Device) (string, error) {
+	return d.getFeatureString(0x06, 32, 2)
+}
+
+func gen2ImagePageHeader(dst []byte, pageIndex, keyIndex, dataSize int, isLast bool) []byte {
 	var last byte
 	if isLast {
 		last = 0x01
 	}
-	return []byte{
+	return append(dst,
 		0x02, 0x07,
 		byte(keyIndex),
 		last,
 		byte(dataSize),
-		byte(dataSize >> 8),
+		byte(dataSize>>8),
 		byte(pageIndex),
-		byte(pageIndex >> 8),
-	}
+		byte(pageIndex>>8),
+	)
 }
 
 func gen2(device *Device) model {
@@ -52,6 +60,8 @@ func gen2(device *Device) model {
 		Device:              device,
 		reset:               gen2Reset,
 		setBrightness:       gen2SetBrightness,
+		firmware:            gen2Firmware,
+		serial:              gen2Serial,
 		imagePageHeader:     gen2ImagePageHeader,
 		imagePageHeaderSize: gen2ImagePageHeaderSize,
 		imagePageSize:       gen2ImagePageSize,
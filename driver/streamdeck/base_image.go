@@ -1,9 +1,12 @@
 package streamdeck
 
 import (
-	"bytes"
 	"image"
 	"image/jpeg"
+	"sync"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/internal/imageutil"
 )
 
 var bmpHeader = []byte{
@@ -16,24 +19,69 @@ var bmpHeader = []byte{
 	0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
 }
 
-func convertBMP(i *image.NRGBA) ([]byte, error) {
-	var b bytes.Buffer
-	b.Write(bmpHeader)
-	for y := i.Rect.Min.Y; y < i.Rect.Max.Y; y++ {
-		for x := i.Rect.Min.X; x < i.Rect.Max.X; x++ {
-			c := i.NRGBAAt(x, y)
-			b.Write([]byte{c.B, c.G, c.R})
+// appendBMP appends the BMP encoding of i to dst.
+func appendBMP(dst []byte, i *image.NRGBA) ([]byte, error) {
+	var (
+		size = i.Rect.Size()
+		n    = len(dst) + len(bmpHeader) + size.X*size.Y*3
+	)
+	if cap(dst) < n {
+		b := make([]byte, len(dst), n)
+		copy(b, dst)
+		dst = b
+	}
+	dst = append(dst, bmpHeader...)
+	for y := 0; y < size.Y; y++ {
+		s := i.Pix[i.PixOffset(i.Rect.Min.X, i.Rect.Min.Y+y):][: size.X*4 : size.X*4]
+		for x := 0; x < len(s); x += 4 {
+			dst = append(dst, s[x+2], s[x+1], s[x+0])
 		}
 	}
-	return b.Bytes(), nil
+	return dst, nil
+}
+
+// jpegEncoder holds the buffers for encoding JPEG images, see jpegEncoders.
+type jpegEncoder struct {
+	rgba    image.RGBA
+	options jpeg.Options
+	buffer  appendWriter
+}
+
+// jpegEncoders holds encoders for reuse, encoding from an *image.RGBA avoids
+// the per pixel conversions image/jpeg does for other image types.
+var jpegEncoders = sync.Pool{New: func() any { return new(jpegEncoder) }}
+
+// appendJPEG appends the JPEG encoding of i to dst.
+func appendJPEG(dst []byte, i *image.NRGBA, quality int) ([]byte, error) {
+	e := jpegEncoders.Get().(*jpegEncoder)
+	defer jpegEncoders.Put(e)
+
+	imageutil.ToRGBA(&e.rgba, i)
+	e.options.Quality = quality
+	e.buffer.b = dst
+	err := jpeg.Encode(&e.buffer, &e.rgba, &e.options)
+	dst, e.buffer.b = e.buffer.b, nil
+	return dst, err
+}
+
+// appendWriter appends to a byte slice, it implements the buffered writer
+// interface of image/jpeg, so no intermediate buffer is used.
+type appendWriter struct {
+	b []byte
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.b = append(w.b, p...)
+	return len(p), nil
 }
 
-func convertJPEG(i image.Image) ([]byte, error) {
-	var b bytes.Buffer
-	err := jpeg.Encode(&b, i, &jpeg.Options{Quality: 100})
-	return b.Bytes(), err
+func (w *appendWriter) WriteByte(c byte) error {
+	w.b = append(w.b, c)
+	return nil
 }
 
+func (w *appendWriter) Flush() error { return nil }
+
 type imageTransform interface {
 	Transform(*image.NRGBA)
 }
@@ -84,6 +132,21 @@ func rotate180(i *image.NRGBA) {
 	}
 }
 
+// rotateImage renders the logical image src into dst in the physical
+// orientation, for a surface mounted with rotation r.
+func rotateImage(dst, src *image.NRGBA, r benjamin.Rotation) {
+	size := dst.Rect.Size()
+	for y := 0; y < size.Y; y++ {
+		o := dst.PixOffset(dst.Rect.Min.X, dst.Rect.Min.Y+y)
+		for x := 0; x < size.X; x++ {
+			p := r.Point(image.Pt(x, y), size)
+			i := src.PixOffset(src.Rect.Min.X+p.X, src.Rect.Min.Y+p.Y)
+			copy(dst.Pix[o:o+4:o+4], src.Pix[i:i+4:i+4])
+			o += 4
+		}
+	}
+}
+
 func reverse(pix []uint8) []uint8 {
 	if len(pix) <= 4 {
 		return pix
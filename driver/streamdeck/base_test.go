@@ -0,0 +1,620 @@
+package streamdeck
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	"github.com/karalabe/hid"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+var testModels = []Properties{Orig, Mini, MiniMK2, MK2, V2, XL, Plus, Neo}
+
+func testDevice(t *testing.T, prop Properties) (*Device, *transport.Memory) {
+	t.Helper()
+	m := transport.NewMemory()
+	d := NewWithTransport(hid.DeviceInfo{VendorID: VendorID, ProductID: prop.ProductID}, prop, m)
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	return d, m
+}
+
+func testImage(size image.Point, c color.Color) *image.NRGBA {
+	i := image.NewNRGBA(image.Rectangle{Max: size})
+	draw.Draw(i, i.Rect, image.NewUniform(c), image.Point{}, draw.Src)
+	return i
+}
+
+func TestSetButtonImagePackets(t *testing.T) {
+	for _, prop := range testModels {
+		t.Run(prop.Model, func(t *testing.T) {
+			d, m := testDevice(t, prop)
+			defer d.Close()
+
+			const index = 1
+			i := testImage(prop.keySize, color.NRGBA{R: 0xff, A: 0xff})
+			if err := d.Button(index).SetImage(i); err != nil {
+				t.Fatal(err)
+			}
+
+			want, _ := Encode(nil, d.key[index].image, prop.keyImageFormat(), DefaultJPEGQuality)
+
+			var (
+				writes   = m.Writes()
+				pageSize = prop.imagePageSize - prop.imagePageHeaderSize
+				pages    = (len(want) + pageSize - 1) / pageSize
+				got      []byte
+			)
+			if len(writes) != pages {
+				t.Fatalf("expected %d packets, got %d", pages, len(writes))
+			}
+			for page, p := range writes {
+				if len(p) != prop.imagePageSize {
+					t.Fatalf("packet %d: expected %d bytes, got %d", page, prop.imagePageSize, len(p))
+				}
+
+				var (
+					last   = page == pages-1
+					size   = pageSize
+					header []byte
+				)
+				if last {
+					size = len(want) - page*pageSize
+				}
+				if prop.imagePageHeaderSize == gen1ImagePageHeaderSize {
+					header = gen1ImagePageHeader(nil, page, index, size, last)
+				} else {
+					header = gen2ImagePageHeader(nil, page, index, size, last)
+				}
+				if !bytes.Equal(p[:len(header)], header) {
+					t.Errorf("packet %d: expected header % x, got % x", page, header, p[:len(header)])
+				}
+				got = append(got, p[len(header):len(header)+size]...)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("image payload mismatch: expected %d bytes, got %d", len(want), len(got))
+			}
+		})
+	}
+}
+
+func TestGen2ImagePageHeader(t *testing.T) {
+	want := []byte{0x02, 0x07, 0x03, 0x01, 0x10, 0x01, 0x02, 0x00}
+	if got := gen2ImagePageHeader(nil, 2, 3, 0x110, true); !bytes.Equal(got, want) {
+		t.Errorf("expected % x, got % x", want, got)
+	}
+}
+
+func TestSetBrightness(t *testing.T) {
+	for _, prop := range testModels {
+		t.Run(prop.Model, func(t *testing.T) {
+			d, m := testDevice(t, prop)
+			defer d.Close()
+
+			if err := d.SetBrightness(0.5); err != nil {
+				t.Fatal(err)
+			}
+
+			var want []byte
+			if prop.imagePageHeaderSize == gen1ImagePageHeaderSize {
+				want = []byte{0x05, 0x55, 0xaa, 0xd1, 0x01, 50}
+			} else {
+				want = []byte{0x03, 0x08, 50}
+			}
+			sent := m.FeatureReports()
+			if len(sent) != 1 {
+				t.Fatalf("expected 1 feature report, got %d", len(sent))
+			}
+			if !bytes.HasPrefix(sent[0], want) {
+				t.Errorf("expected report starting with % x, got % x", want, sent[0])
+			}
+		})
+	}
+}
+
+func TestReplayEvents(t *testing.T) {
+	const capture = `{"time":"2023-03-01T12:00:00Z","op":"read","data":"01000f0000010000000000000000000000"}
+{"time":"2023-03-01T12:00:01Z","op":"read","data":"01000f0000000000000000000000000000"}
+`
+	r, err := transport.NewReplay(strings.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		d    = NewWithTransport(hid.DeviceInfo{}, MK2, r)
+		want = []benjamin.EventType{
+			benjamin.TypeButtonPress,
+			benjamin.TypeButtonRelease,
+			benjamin.TypeError,
+		}
+		got []benjamin.EventType
+	)
+	for event := range d.Events() {
+		if event.Peripheral != nil && event.Peripheral != d.Button(1) {
+			t.Errorf("expected event on button 1, got %d", event.Peripheral.Index())
+		}
+		got = append(got, event.Type)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestFirmware(t *testing.T) {
+	tests := []struct {
+		Properties
+		Report   []byte
+		Firmware string
+	}{
+		{Mini, []byte{0x04, 0x0c, 0xd2, 0x4e, 0x45, '2', '.', '0', '3', 0x00, 0x00}, "2.03"},
+		{XL, []byte{0x05, 0x0c, 0xd2, 0x4e, 0x45, 0x00, '1', '.', '0', '1', '.', '0', '0', '0', 0x00}, "1.01.000"},
+	}
+	for _, test := range tests {
+		t.Run(test.Model, func(t *testing.T) {
+			d, m := testDevice(t, test.Properties)
+			defer d.Close()
+
+			m.SetFeatureReport(test.Report)
+			v, err := d.Firmware()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != test.Firmware {
+				t.Errorf("expected firmware %q, got %q", test.Firmware, v)
+			}
+		})
+	}
+}
+
+func TestNeo(t *testing.T) {
+	d, m := testDevice(t, Neo)
+	defer d.Close()
+
+	if n := d.Buttons(); n != 10 {
+		t.Errorf("expected 10 buttons, got %d", n)
+	}
+	if b := d.ButtonAt(image.Pt(3, 2)); b == nil || b.Index() != 9 {
+		t.Error("expected right touch key at (3,2)")
+	}
+	if err := d.Button(8).SetImage(image.Black); err == nil {
+		t.Error("expected error setting image on touch key")
+	}
+
+	if err := d.Display(0).SetImage(testImage(Neo.displaySize, color.White)); err != nil {
+		t.Fatal(err)
+	}
+	writes := m.Writes()
+	if len(writes) == 0 {
+		t.Fatal("expected info bar image packets")
+	}
+	if h := writes[0][:3]; !bytes.Equal(h, []byte{0x02, 0x0b, 0x00}) {
+		t.Errorf("expected info bar header, got % x", h)
+	}
+}
+
+func TestNeoRotation(t *testing.T) {
+	tests := []struct {
+		rotation    benjamin.Rotation
+		left, right image.Point
+	}{
+		{benjamin.Rotate0, image.Pt(0, 2), image.Pt(3, 2)},
+		{benjamin.Rotate90, image.Pt(0, 4), image.Pt(1, 4)},
+		{benjamin.Rotate180, image.Pt(3, 2), image.Pt(0, 2)},
+		{benjamin.Rotate270, image.Pt(1, 4), image.Pt(0, 4)},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.rotation.String(), func(t *testing.T) {
+			d, _ := testDevice(t, Neo)
+			defer d.Close()
+
+			d.SetRotation(test.rotation)
+			for i, want := range []image.Point{test.left, test.right} {
+				b := d.Button(Neo.keys + i)
+				if p := b.Position(); !p.Eq(want) {
+					t.Errorf("expected touch key %d at %s, got %s", i, want, p)
+				}
+				if a := d.ButtonAt(want); a == nil || a.Index() != b.Index() {
+					t.Errorf("expected touch key %d at %s", i, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPedal(t *testing.T) {
+	d, m := testDevice(t, Pedal)
+	defer d.Close()
+
+	for i := 0; i < d.Buttons(); i++ {
+		b := d.Button(i)
+		if benjamin.HasScreen(b) {
+			t.Errorf("expected button %d to have no screen", i)
+		}
+		if err := b.SetImage(image.Black); !errors.Is(err, benjamin.ErrNotSupported) {
+			t.Errorf("expected ErrNotSupported, got %v", err)
+		}
+	}
+	if d.ButtonArea() != nil {
+		t.Error("expected no button area")
+	}
+	if err := d.Clear(); err != nil {
+		t.Error(err)
+	}
+
+	m.Reset()
+	if err := d.Reset(); !errors.Is(err, benjamin.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+	if r := m.FeatureReports(); len(r) != 0 {
+		t.Errorf("expected no feature reports, got % x", r)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	d, _ := testDevice(t, MK2)
+	defer d.Close()
+
+	d.SetRotation(benjamin.Rotate90)
+	if l := d.ButtonLayout(); !l.Eq(image.Pt(3, 5)) {
+		t.Errorf("expected 3x5 layout, got %s", l)
+	}
+	// The physical bottom left key is at the top left when rotated clockwise.
+	b := d.ButtonAt(image.Pt(0, 0))
+	if b == nil || b.Index() != 10 {
+		t.Fatalf("expected key 10 at (0,0), got %v", b)
+	}
+	if p := b.Position(); !p.Eq(image.Pt(0, 0)) {
+		t.Errorf("expected key 10 at (0,0), got %s", p)
+	}
+	if s := d.ButtonArea().Size(); !s.Eq(image.Pt(3*72, 5*72)) {
+		t.Errorf("expected 216x360 button area, got %s", s)
+	}
+
+	// Mounted upside down cancels out the rotation of the MK.2 key images.
+	d.SetRotation(benjamin.Rotate180)
+	i := testImage(MK2.keySize, color.Black)
+	i.Set(0, 0, color.White)
+	k := d.Button(0).(*key)
+	if err := k.SetImage(i); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(k.frame.Pix, i.Pix) {
+		t.Error("expected key image to be sent upright")
+	}
+}
+
+func TestRotationTouch(t *testing.T) {
+	d, _ := testDevice(t, Plus)
+	defer d.Close()
+	d.SetRotation(benjamin.Rotate180)
+
+	p := make([]byte, 16)
+	p[1] = 0x02 // display
+	p[4] = 0x01 // short press
+	p[6], p[8] = 10, 20
+	c := make(chan benjamin.Event, 1)
+	d.Handle(p, c)
+
+	event := <-c
+	if at := event.Data.(benjamin.DisplayPress).Position; !at.Eq(image.Pt(789, 79)) {
+		t.Errorf("expected touch at (789,79), got %s", at)
+	}
+}
+
+func TestTouchEdge(t *testing.T) {
+	d, _ := testDevice(t, Plus)
+	defer d.Close()
+
+	p := make([]byte, 16)
+	p[1] = 0x02             // display
+	p[4] = 0x03             // swipe
+	p[6], p[7] = 0x20, 0x03 // x=800
+	p[8] = 100
+	p[10], p[11] = 0x20, 0x03
+	p[12] = 100
+	c := make(chan benjamin.Event, 1)
+	d.Handle(p, c)
+
+	event := <-c
+	swipe := event.Data.(benjamin.DisplaySwipe)
+	if swipe.Display != d.Display(3) {
+		t.Errorf("expected swipe on display 3, got %v", swipe.Display)
+	}
+	if want := image.Pt(799, 99); !swipe.From.Eq(want) || !swipe.To.Eq(want) {
+		t.Errorf("expected swipe at %s, got %s-%s", want, swipe.From, swipe.To)
+	}
+}
+
+func TestBezel(t *testing.T) {
+	d, _ := testDevice(t, MK2)
+	defer d.Close()
+
+	d.SetBezel(true)
+	area := d.ButtonArea()
+	if s := area.Size(); !s.Eq(image.Pt(5*72+4*25, 3*72+2*25)) {
+		t.Fatalf("expected 460x266 button area, got %s", s)
+	}
+
+	// Mark the top left pixel of the second key, and the gap before it.
+	i := testImage(area.Size(), color.Black)
+	i.Set(72+25, 0, color.White)
+	i.Set(72+24, 0, color.White)
+	if err := area.SetImage(i); err != nil {
+		t.Fatal(err)
+	}
+	k := d.Button(1).(*key)
+	if c := k.image.NRGBAAt(0, 0); c.R != 0xff {
+		t.Errorf("expected key 1 to start after the gap, got %v", c)
+	}
+	if c := d.Button(0).(*key).image.NRGBAAt(71, 0); c.R != 0 {
+		t.Errorf("expected gap pixels to be dropped, got %v", c)
+	}
+}
+
+func TestButtonAreaStride(t *testing.T) {
+	d, _ := testDevice(t, Mini)
+	defer d.Close()
+
+	// A view at the origin of a wider image, as passed by the virtual device.
+	var (
+		size = d.ButtonArea().Size()
+		wide = testImage(image.Pt(size.X*2, size.Y), color.Black)
+		view = &image.NRGBA{Pix: wide.Pix, Stride: wide.Stride, Rect: image.Rectangle{Max: size}}
+	)
+	view.Set(0, 80, color.White)
+	if err := d.ButtonArea().SetImage(view); err != nil {
+		t.Fatal(err)
+	}
+	if c := d.Button(3).(*key).image.NRGBAAt(0, 0); c.R != 0xff {
+		t.Errorf("expected key 3 to be white at the origin, got %v", c)
+	}
+}
+
+func TestSkipUnchanged(t *testing.T) {
+	for _, prop := range testModels {
+		t.Run(prop.Model, func(t *testing.T) {
+			d, m := testDevice(t, prop)
+			defer d.Close()
+
+			i := testImage(prop.keySize, color.White)
+			for n := 0; n < 2; n++ {
+				if err := d.Button(0).SetImage(i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			sent := len(m.Writes())
+
+			d.Invalidate()
+			if err := d.Button(0).SetImage(i); err != nil {
+				t.Fatal(err)
+			}
+			if n := len(m.Writes()); n != 2*sent {
+				t.Errorf("expected unchanged frame to be skipped once and sent after invalidate, got %d and %d writes", sent, n)
+			}
+		})
+	}
+}
+
+func TestPartialDisplay(t *testing.T) {
+	d, m := testDevice(t, Plus)
+	defer d.Close()
+
+	rect := func(p []byte) image.Rectangle {
+		var (
+			x = int(p[2]) | int(p[3])<<8
+			y = int(p[4]) | int(p[5])<<8
+			w = int(p[6]) | int(p[7])<<8
+			h = int(p[8]) | int(p[9])<<8
+		)
+		return image.Rect(x, y, x+w, y+h)
+	}
+
+	if err := d.Display(1).SetImage(testImage(Plus.displaySize, color.White)); err != nil {
+		t.Fatal(err)
+	}
+	if r := rect(m.Writes()[0]); !r.Eq(image.Rect(200, 0, 400, 100)) {
+		t.Errorf("expected only display 1 to be sent, got %s", r)
+	}
+
+	m.Reset()
+	area := d.DisplayArea().(benjamin.PartialDrawable)
+	dirty := image.Rect(10, 20, 50, 60)
+	if err := area.SetImageRect(testImage(area.Size(), color.Black), dirty); err != nil {
+		t.Fatal(err)
+	}
+	if r := rect(m.Writes()[0]); !r.Eq(dirty) {
+		t.Errorf("expected dirty rectangle %s to be sent, got %s", dirty, r)
+	}
+}
+
+func TestCompositor(t *testing.T) {
+	d, _ := testDevice(t, Plus)
+	defer d.Close()
+
+	if err := d.DisplayArea().SetImage(testImage(d.DisplayArea().Size(), color.White)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Display(1).SetImage(testImage(Plus.displaySize, color.Black)); err != nil {
+		t.Fatal(err)
+	}
+
+	white := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	if c := d.strip.frame.NRGBAAt(100, 50); c != white {
+		t.Errorf("expected display area to show on display 0, got %v", c)
+	}
+	if c := d.strip.frame.NRGBAAt(300, 50); c == white {
+		t.Errorf("expected display 1 to show on top of the display area, got %v", c)
+	}
+
+	if err := d.Display(1).SetImage(nil); err != nil {
+		t.Fatal(err)
+	}
+	if c := d.strip.frame.NRGBAAt(300, 50); c != white {
+		t.Errorf("expected display area to show after removing display 1, got %v", c)
+	}
+}
+
+func TestCommitBatch(t *testing.T) {
+	d, m := testDevice(t, Plus)
+	defer d.Close()
+
+	other, _ := testDevice(t, Plus)
+	defer other.Close()
+
+	b := benjamin.NewBatch(d)
+	b.Set(d.ButtonArea(), testImage(d.ButtonArea().Size(), color.White))
+	b.Set(d.Button(1), testImage(Plus.keySize, color.Black))
+	b.Set(d.Display(0), testImage(Plus.displaySize, color.White))
+	b.Set(d.Display(2), testImage(Plus.displaySize, color.White))
+	b.Set(other.Button(0), testImage(Plus.keySize, color.White))
+
+	err := b.Commit()
+	var failed *benjamin.BatchError
+	if !errors.As(err, &failed) {
+		t.Fatalf("expected batch error, got %v", err)
+	}
+	if len(failed.Drawables) != 1 || failed.Drawables[0] != other.Button(0) {
+		t.Errorf("expected the button of the other device to fail, got %v", err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("expected batch to be reset after commit, got %d staged", b.Len())
+	}
+
+	var (
+		keys     []int
+		displays int
+	)
+	for _, p := range m.Writes() {
+		switch {
+		case p[1] == 0x07 && p[6] == 0 && p[7] == 0: // first page of a key
+			keys = append(keys, int(p[2]))
+		case p[1] == 0x0c && p[11] == 0 && p[12] == 0: // first page of the strip
+			displays++
+		}
+	}
+	if len(keys) != Plus.keys {
+		t.Fatalf("expected %d keys to be sent, got %v", Plus.keys, keys)
+	}
+	for n, index := range keys {
+		if index != n {
+			t.Fatalf("expected keys to be sent in order, got %v", keys)
+		}
+	}
+	if displays != 1 {
+		t.Errorf("expected displays to be sent in one transfer, got %d", displays)
+	}
+
+	white := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	if c := d.key[0].image.NRGBAAt(0, 0); c != white {
+		t.Errorf("expected key 0 to show the button area, got %v", c)
+	}
+	if c := d.key[1].image.NRGBAAt(0, 0); c == white {
+		t.Errorf("expected key 1 to show its own image, got %v", c)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	var (
+		bmp72  = benjamin.PeripheralCapabilities{Screen: true, Size: image.Pt(72, 72), Format: benjamin.FormatBMP, Push: true}
+		jpeg72 = benjamin.PeripheralCapabilities{Screen: true, Size: image.Pt(72, 72), Format: benjamin.FormatJPEG, Push: true}
+		bmp80  = benjamin.PeripheralCapabilities{Screen: true, Size: image.Pt(80, 80), Format: benjamin.FormatBMP, Push: true}
+		jpeg96 = benjamin.PeripheralCapabilities{Screen: true, Size: image.Pt(96, 96), Format: benjamin.FormatJPEG, Push: true}
+		touch  = benjamin.PeripheralCapabilities{Touch: true}
+		push   = benjamin.PeripheralCapabilities{Push: true}
+	)
+	tests := []struct {
+		prop       Properties
+		keys       int
+		key        benjamin.PeripheralCapabilities
+		touchKeys  int
+		displays   int
+		display    benjamin.PeripheralCapabilities
+		encoders   int
+		brightness bool
+	}{
+		{prop: Orig, keys: 15, key: bmp72, brightness: true},
+		{prop: Mini, keys: 6, key: bmp80, brightness: true},
+		{prop: MiniMK2, keys: 6, key: bmp80, brightness: true},
+		{prop: MK2, keys: 15, key: jpeg72, brightness: true},
+		{prop: V2, keys: 15, key: jpeg72, brightness: true},
+		{prop: XL, keys: 32, key: jpeg96, brightness: true},
+		{
+			prop: Plus, keys: 8, encoders: 4, brightness: true,
+			key:      benjamin.PeripheralCapabilities{Screen: true, Size: image.Pt(120, 120), Format: benjamin.FormatJPEG, Push: true},
+			displays: 4,
+			display:  benjamin.PeripheralCapabilities{Screen: true, Size: image.Pt(200, 100), Format: benjamin.FormatJPEG, Touch: true},
+		},
+		{
+			prop: Neo, keys: 8, key: jpeg96, touchKeys: 2, brightness: true,
+			displays: 1,
+			display:  benjamin.PeripheralCapabilities{Screen: true, Size: image.Pt(248, 58), Format: benjamin.FormatJPEG},
+		},
+		{prop: Pedal, keys: 3, key: push},
+	}
+	for _, test := range tests {
+		t.Run(test.prop.Model, func(t *testing.T) {
+			d, _ := testDevice(t, test.prop)
+			defer d.Close()
+
+			c := benjamin.CapabilitiesOf(d)
+			if n := len(c.Buttons); n != test.keys+test.touchKeys {
+				t.Fatalf("expected %d buttons, got %d", test.keys+test.touchKeys, n)
+			}
+			for i, b := range c.Buttons {
+				want := test.key
+				if i >= test.keys {
+					want = touch
+				}
+				if b != want {
+					t.Errorf("button %d: expected %s, got %s", i, want, b)
+				}
+				if p := benjamin.PeripheralCapabilitiesOf(d.Button(i)); p != b {
+					t.Errorf("button %d: expected peripheral %s, got %s", i, b, p)
+				}
+			}
+			if n := len(c.Displays); n != test.displays {
+				t.Fatalf("expected %d displays, got %d", test.displays, n)
+			}
+			for i, p := range c.Displays {
+				if p != test.display {
+					t.Errorf("display %d: expected %s, got %s", i, test.display, p)
+				}
+				if p := benjamin.PeripheralCapabilitiesOf(d.Display(i)); p != test.display {
+					t.Errorf("display %d: expected peripheral %s, got %s", i, test.display, p)
+				}
+			}
+			if n := len(c.Encoders); n != test.encoders {
+				t.Fatalf("expected %d encoders, got %d", test.encoders, n)
+			}
+			for i, p := range c.Encoders {
+				if p != push {
+					t.Errorf("encoder %d: expected %s, got %s", i, push, p)
+				}
+				if p := benjamin.PeripheralCapabilitiesOf(d.Encoder(i)); p != push {
+					t.Errorf("encoder %d: expected peripheral %s, got %s", i, push, p)
+				}
+			}
+			if ok := c.Brightness.Supported(); ok != test.brightness {
+				t.Errorf("expected brightness supported %t, got %t", test.brightness, ok)
+			}
+			if c.StandbyImage {
+				t.Error("expected no standby image")
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package streamdeck
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+
+	"github.com/tehmaze/benjamin"
+)
+
+var errForeignDrawable = errors.New("streamdeck: drawable is not on this device")
+
+// batchFrame is a frame of a batch commit.
+type batchFrame struct {
+	target    writeTarget
+	frame     *image.NRGBA
+	format    benjamin.ImageFormat
+	render    func() // renders frame, may be nil
+	buf       *frameBuffer
+	err       error
+	drawables []benjamin.Drawable // staged drawables shown in the frame
+}
+
+// CommitBatch sends the staged images, see benjamin.Batch. All frames are
+// rendered and encoded before the first frame is written, so the frames are
+// written back to back; the keys are written in order, followed by the
+// displays. Multiple display images are combined in one transfer.
+//
+// Images staged for buttons are shown on top of the image staged for the
+// button area, images staged for displays are shown on top of the display
+// area, like with SetImage.
+func (d *Device) CommitBatch(staged []benjamin.Staged) error {
+	var (
+		failed   = new(benjamin.BatchError)
+		area     *benjamin.Staged
+		keys     = make(map[int]benjamin.Staged)
+		layers   = make(map[int]benjamin.Staged) // by layer of the strip
+		frames   []*batchFrame
+		keyOrder []int
+	)
+	for n, s := range staged {
+		switch p := s.Drawable.(type) {
+		case *key:
+			if p.device != d {
+				break
+			}
+			if p.image == nil {
+				failed.Add(p, fmt.Errorf("streamdeck: key %d: %w", p.index, benjamin.ErrNotSupported))
+			} else {
+				keys[p.index] = s
+			}
+			continue
+		case *keyArea:
+			if p != nil && p.device == d {
+				area = &staged[n]
+				continue
+			}
+		case *display:
+			if p.device == d {
+				layers[p.index+1] = s
+				continue
+			}
+		case *displayArea:
+			if p != nil && p.device == d {
+				layers[0] = s
+				continue
+			}
+		}
+		failed.Add(s.Drawable, errForeignDrawable)
+	}
+
+	// Keys, in order.
+	if area != nil {
+		d.keyArea.fill(area.Image)
+		for _, k := range d.key {
+			if k.image != nil {
+				keyOrder = append(keyOrder, k.index)
+			}
+		}
+	} else {
+		for index := range keys {
+			keyOrder = append(keyOrder, index)
+		}
+		sort.Ints(keyOrder)
+	}
+	var pitch image.Point
+	if area != nil {
+		pitch = d.keyArea.pitch()
+	}
+	for _, index := range keyOrder {
+		var (
+			k      = d.key[index]
+			s, set = keys[index]
+			f      = &batchFrame{
+				target: k.target(),
+				frame:  k.frame,
+				format: d.prop.keyImageFormat(),
+			}
+		)
+		switch {
+		case set:
+			f.render = func() { k.render(s.Image) }
+			f.drawables = append(f.drawables, s.Drawable)
+		default:
+			f.render = func() { d.keyArea.renderKey(k, pitch) }
+		}
+		if area != nil {
+			f.drawables = append(f.drawables, area.Drawable)
+		}
+		frames = append(frames, f)
+	}
+
+	// Displays, composed in one frame.
+	if len(layers) > 0 {
+		d.stripMu.Lock()
+		defer d.stripMu.Unlock()
+
+		var (
+			r         image.Rectangle
+			drawables []benjamin.Drawable
+		)
+		for n := 0; n <= d.prop.displays; n++ {
+			s, ok := layers[n]
+			if !ok {
+				continue
+			}
+			interpolator := d.encoding.buttonInterpolator()
+			if n == 0 {
+				interpolator = d.encoding.displayInterpolator()
+			}
+			r = r.Union(d.updateLayer(n, setLayer(s.Image, interpolator)))
+			drawables = append(drawables, s.Drawable)
+		}
+		if target, frame := d.stripFrame(r); frame != nil {
+			frames = append(frames, &batchFrame{
+				target:    target,
+				frame:     frame,
+				format:    benjamin.FormatJPEG,
+				drawables: drawables,
+			})
+		}
+	}
+
+	parallel(len(frames), func(n int) {
+		f := frames[n]
+		if f.render != nil {
+			f.render()
+		}
+		f.buf, f.err = d.encode(f.target, f.frame, f.format)
+	})
+
+	for _, f := range frames {
+		err := f.err
+		if err == nil {
+			err = d.deliver(f.target, f.buf)
+		}
+		if err != nil {
+			for _, drawable := range f.drawables {
+				failed.Add(drawable, err)
+			}
+		}
+	}
+	return failed.Err()
+}
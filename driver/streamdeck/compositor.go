@@ -0,0 +1,169 @@
+package streamdeck
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/internal/imageutil"
+)
+
+// compositor composes the display area and the displays into one framebuffer
+// for the display strip, in logical coordinates. The display area is the
+// bottom layer, each display is a layer on top of its region of the strip.
+type compositor struct {
+	rotation benjamin.Rotation
+	frame    *image.NRGBA
+	layers   []*layer // display area first, followed by the displays
+}
+
+// layer of the compositor, hidden layers show the layers below.
+type layer struct {
+	rect  image.Rectangle // in frame coordinates
+	image *image.NRGBA    // size of rect, at the origin
+	shown bool
+}
+
+func newLayer(r image.Rectangle) *layer {
+	return &layer{
+		rect:  r,
+		image: image.NewNRGBA(image.Rectangle{Max: r.Size()}),
+	}
+}
+
+func newCompositor(d *Device) *compositor {
+	var (
+		physical = d.stripSize()
+		size     = d.prop.displaySize
+		c        = &compositor{
+			rotation: d.rotation,
+			frame:    image.NewNRGBA(image.Rectangle{Max: d.rotation.Size(physical)}),
+		}
+	)
+	c.layers = append(c.layers, newLayer(c.frame.Rect))
+	for i := 0; i < d.prop.displays; i++ {
+		slot := image.Rect(i*size.X, 0, (i+1)*size.X, size.Y)
+		c.layers = append(c.layers, newLayer(rotateRect(slot, physical, d.rotation)))
+	}
+	c.compose(c.frame.Rect)
+	return c
+}
+
+// compose the region r of the framebuffer.
+func (c *compositor) compose(r image.Rectangle) {
+	r = r.Intersect(c.frame.Rect)
+	draw.Draw(c.frame, r, image.Black, image.Point{}, draw.Src)
+	for _, l := range c.layers {
+		if !l.shown {
+			continue
+		}
+		if lr := r.Intersect(l.rect); !lr.Empty() {
+			draw.Draw(c.frame, lr, l.image, lr.Min.Sub(l.rect.Min), draw.Over)
+		}
+	}
+}
+
+// stripSize is the physical size of the display strip.
+func (d *Device) stripSize() image.Point {
+	var (
+		l = d.prop.displayLayout
+		s = d.prop.displaySize
+	)
+	return image.Pt(l.X*s.X, l.Y*s.Y)
+}
+
+// updateStrip updates layer n of the display strip. The update function
+// returns the changed region of the layer, which is composed and sent.
+func (d *Device) updateStrip(n int, update func(*layer) image.Rectangle) error {
+	d.stripMu.Lock()
+	defer d.stripMu.Unlock()
+
+	target, frame := d.stripFrame(d.updateLayer(n, update))
+	if frame == nil {
+		return nil
+	}
+	return d.send(target, frame, benjamin.FormatJPEG)
+}
+
+// updateLayer updates layer n of the display strip, and returns the changed
+// region of the strip. The caller must hold stripMu.
+func (d *Device) updateLayer(n int, update func(*layer) image.Rectangle) image.Rectangle {
+	if d.strip == nil || d.strip.rotation != d.rotation {
+		d.strip = newCompositor(d)
+	}
+
+	l := d.strip.layers[n]
+	r := update(l).Intersect(l.image.Rect)
+	if r.Empty() {
+		return image.Rectangle{}
+	}
+	return r.Add(l.rect.Min)
+}
+
+// stripFrame composes the region r of the display strip, and returns the frame
+// to send. The frame is nil if there is nothing to send. The caller must hold
+// stripMu.
+func (d *Device) stripFrame(r image.Rectangle) (writeTarget, *image.NRGBA) {
+	if r.Empty() {
+		return writeTarget{}, nil
+	}
+	d.strip.compose(r)
+
+	frame := d.rotate(&d.displayFrame, d.strip.frame)
+	return d.displayRegion(frame, rotateRect(r, d.strip.frame.Rect.Size(), d.rotation.Inverse()))
+}
+
+// setLayer returns an update that sets the image of a layer, scaled to fit. A
+// nil image hides the layer.
+func setLayer(i image.Image, interpolator draw.Interpolator) func(*layer) image.Rectangle {
+	return func(l *layer) image.Rectangle {
+		if l.shown = i != nil; l.shown {
+			scaleInto(l.image, i, interpolator)
+		}
+		return l.image.Rect
+	}
+}
+
+// clearStrip hides all layers of the display strip.
+func (d *Device) clearStrip() error {
+	return d.updateStrip(0, func(l *layer) image.Rectangle {
+		for _, l := range d.strip.layers {
+			l.shown = false
+		}
+		return l.image.Rect
+	})
+}
+
+// rotateRect maps rectangle r in an area of size with rotation.
+func rotateRect(r image.Rectangle, size image.Point, rotation benjamin.Rotation) image.Rectangle {
+	if rotation == benjamin.Rotate0 || r.Empty() {
+		return r
+	}
+	var (
+		a = rotation.Point(r.Min, size)
+		b = rotation.Point(r.Max.Sub(image.Pt(1, 1)), size)
+	)
+	if a.X > b.X {
+		a.X, b.X = b.X, a.X
+	}
+	if a.Y > b.Y {
+		a.Y, b.Y = b.Y, a.Y
+	}
+	return image.Rectangle{Min: a, Max: b.Add(image.Pt(1, 1))}
+}
+
+// scaleInto draws i into dst, scaled to fit.
+func scaleInto(dst *image.NRGBA, i image.Image, interpolator draw.Interpolator) {
+	switch o := i.(type) {
+	case *image.Uniform:
+		draw.Draw(dst, dst.Rect, o, image.Point{}, draw.Src)
+		return
+	case *image.NRGBA:
+		if o.Rect.Size().Eq(dst.Rect.Size()) {
+			imageutil.CopyNRGBA(dst, dst.Rect.Min, o, o.Rect)
+			return
+		}
+	}
+	interpolator.Scale(dst, dst.Rect, i, i.Bounds(), draw.Src, nil)
+}
@@ -0,0 +1,114 @@
+package streamdeck
+
+import (
+	"fmt"
+	"image"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/image/draw"
+
+	"github.com/tehmaze/benjamin"
+)
+
+// DefaultJPEGQuality is the JPEG quality used if the Encoding has no Quality.
+const DefaultJPEGQuality = 100
+
+// EncodeConcurrency is the maximum number of frames that are encoded
+// concurrently, for updates that span multiple keys.
+var EncodeConcurrency = runtime.GOMAXPROCS(0)
+
+// parallel calls f for 0 <= i < n, on at most EncodeConcurrency goroutines.
+func parallel(n int, f func(i int)) {
+	workers := EncodeConcurrency
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+
+	var (
+		wg   sync.WaitGroup
+		next int32 = -1
+	)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := int(atomic.AddInt32(&next, 1)); i < n; i = int(atomic.AddInt32(&next, 1)) {
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Encoder appends the encoding of a frame in the image format of the peripheral
+// to dst, quality is the JPEG quality from 1 to 100. The frame and dst are
+// reused after the Encoder returns.
+type Encoder func(dst []byte, i *image.NRGBA, format benjamin.ImageFormat, quality int) ([]byte, error)
+
+// Encode is the default Encoder, it uses the standard library JPEG encoder,
+// that only supports 4:2:0 chroma subsampling.
+func Encode(dst []byte, i *image.NRGBA, format benjamin.ImageFormat, quality int) ([]byte, error) {
+	switch format {
+	case benjamin.FormatBMP:
+		return appendBMP(dst, i)
+	case benjamin.FormatJPEG:
+		return appendJPEG(dst, i, quality)
+	default:
+		return dst, fmt.Errorf("streamdeck: can't encode %s images: %w", format, benjamin.ErrNotSupported)
+	}
+}
+
+// Encoding options for the images sent to a device. The zero value uses the
+// package defaults.
+type Encoding struct {
+	// Quality of JPEG images, from 1 to 100. Lower qualities encode faster
+	// and result in smaller frames.
+	Quality int
+
+	// ButtonInterpolator scales images set on buttons and displays.
+	ButtonInterpolator draw.Interpolator
+
+	// DisplayInterpolator scales images set on the button and display area.
+	DisplayInterpolator draw.Interpolator
+
+	// Encoder encodes frames instead of Encode. The built-in JPEG encoder only
+	// uses 4:2:0 chroma subsampling, set an Encoder for other subsampling,
+	// such as 4:4:4 for crisp colored text.
+	Encoder Encoder
+}
+
+func (e Encoding) quality() int {
+	if e.Quality <= 0 {
+		return DefaultJPEGQuality
+	}
+	return e.Quality
+}
+
+func (e Encoding) buttonInterpolator() draw.Interpolator {
+	if e.ButtonInterpolator == nil {
+		return ButtonImageInterpolator
+	}
+	return e.ButtonInterpolator
+}
+
+func (e Encoding) displayInterpolator() draw.Interpolator {
+	if e.DisplayInterpolator == nil {
+		return DisplayImageInterpolator
+	}
+	return e.DisplayInterpolator
+}
+
+func (e Encoding) encode(dst []byte, i *image.NRGBA, format benjamin.ImageFormat) ([]byte, error) {
+	if e.Encoder == nil {
+		return Encode(dst, i, format, e.quality())
+	}
+	return e.Encoder(dst, i, format, e.quality())
+}
@@ -0,0 +1,55 @@
+package streamdeck
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/tehmaze/benjamin"
+)
+
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		prop    Properties
+		quality int
+		format  benjamin.ImageFormat
+	}{
+		{XL, 50, benjamin.FormatJPEG},
+		{Mini, 0, benjamin.FormatBMP},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.prop.Model, func(t *testing.T) {
+			d, m := testDevice(t, test.prop)
+			defer d.Close()
+
+			var (
+				format  benjamin.ImageFormat
+				quality int
+			)
+			d.SetEncoding(Encoding{
+				Quality: test.quality,
+				Encoder: func(dst []byte, i *image.NRGBA, f benjamin.ImageFormat, q int) ([]byte, error) {
+					format, quality = f, q
+					return Encode(dst, i, f, q)
+				},
+			})
+			if err := d.Button(0).SetImage(testImage(test.prop.keySize, color.White)); err != nil {
+				t.Fatal(err)
+			}
+			if format != test.format {
+				t.Errorf("expected %s, got %s", test.format, format)
+			}
+			want := test.quality
+			if want == 0 {
+				want = DefaultJPEGQuality
+			}
+			if quality != want {
+				t.Errorf("expected quality %d, got %d", want, quality)
+			}
+			if len(m.Writes()) == 0 {
+				t.Error("expected frame to be sent")
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package streamdeck
 
 import (
+	"fmt"
 	"image"
 	"time"
 
 	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/internal/imageutil"
 	"golang.org/x/image/draw"
 )
 
@@ -21,17 +23,16 @@ var (
 	blank = image.NewNRGBA(image.Rect(0, 0, 800, 100))
 )
 
+// display is a layer of the display strip, see compositor.
 type display struct {
 	device *Device
 	index  int
-	image  *image.NRGBA
 }
 
 func newDisplay(device *Device, index int) *display {
 	return &display{
 		device: device,
 		index:  index,
-		image:  image.NewNRGBA(image.Rectangle{Max: device.prop.displaySize}),
 	}
 }
 
@@ -44,36 +45,35 @@ func (d *display) Index() int {
 }
 
 func (d *display) Size() image.Point {
-	return d.device.prop.displaySize
+	return d.device.rotation.Size(d.device.prop.displaySize)
+}
+
+func (d *display) Capabilities() benjamin.PeripheralCapabilities {
+	c := d.device.prop.displayCapabilities()
+	c.Size = d.Size()
+	return c
 }
 
 func (d *display) Position() image.Point {
 	return image.Pt(0, d.index)
 }
 
+// SetImage sets the image of the display, it is shown on top of the image of
+// the display area. Setting a nil image removes the image of the display, so
+// the display area image shows.
 func (d *display) SetImage(i image.Image) error {
-	if i == nil {
-		i = blank
-	}
-
-	// Fill our key image with the new image.
-	if o, ok := i.(*image.RGBA); ok && o.Rect.Eq(d.image.Rect) {
-		// Fast path, copy pixels.
-		copy(d.image.Pix, o.Pix)
-	} else {
-		// Interpolate image into key image.
-		ButtonImageInterpolator.Scale(d.image, d.image.Rect, i, i.Bounds(), draw.Src, nil)
-	}
-
-	// Copy to general display buffer area.
-	draw.Copy(d.device.displayImage, image.Pt(d.device.prop.displaySize.X*d.index, 0), d.image, d.image.Rect, draw.Src, nil)
-
-	b, err := convertJPEG(d.device.displayImage)
-	if err != nil {
-		return err
-	}
+	return d.device.updateStrip(d.index+1, setLayer(i, d.device.encoding.buttonInterpolator()))
+}
 
-	return d.device.SetDisplayImage(b)
+// SetImageRect updates the rectangle r of the display, only the changed region
+// is sent to devices that support partial updates.
+func (d *display) SetImageRect(i image.Image, r image.Rectangle) error {
+	return d.device.updateStrip(d.index+1, func(l *layer) image.Rectangle {
+		r = r.Intersect(l.image.Rect)
+		draw.Draw(l.image, r, i, r.Min, draw.Src)
+		l.shown = true
+		return r
+	})
 }
 
 type encoder struct {
@@ -98,6 +98,10 @@ func (e *encoder) Index() int {
 	return e.index
 }
 
+func (e *encoder) Capabilities() benjamin.PeripheralCapabilities {
+	return benjamin.PeripheralCapabilities{Push: true}
+}
+
 func (e *encoder) Display() benjamin.Display {
 	if e.index >= e.device.prop.displays {
 		return nil
@@ -111,16 +115,30 @@ type key struct {
 	pos    image.Point
 	state  byte
 	press  time.Time
-	image  *image.NRGBA
+	image  *image.NRGBA // as set, in logical orientation
+	frame  *image.NRGBA // as sent, in physical orientation
 }
 
 func newButton(device *Device, index int) *key {
-	return &key{
+	l := device.prop.keyLayout
+	if index >= device.prop.keys {
+		// Touch keys are below the keys, on the far left and far right.
+		return &key{
+			device: device,
+			pos:    image.Pt((index-device.prop.keys)*(l.X-1), l.Y),
+			index:  index,
+		}
+	}
+	k := &key{
 		device: device,
-		pos:    image.Pt(index%device.prop.keyLayout.X, index/device.prop.keyLayout.X),
+		pos:    image.Pt(index%l.X, index/l.X),
 		index:  index,
-		image:  image.NewNRGBA(image.Rectangle{Max: device.prop.keySize}),
 	}
+	if device.prop.hasKeyImages() {
+		k.image = image.NewNRGBA(image.Rectangle{Max: device.prop.keySize})
+		k.frame = image.NewNRGBA(image.Rectangle{Max: device.prop.keySize})
+	}
+	return k
 }
 
 func (k *key) Surface() benjamin.Surface {
@@ -131,46 +149,74 @@ func (k *key) Index() int {
 	return k.index
 }
 
+func (k *key) Capabilities() benjamin.PeripheralCapabilities {
+	return k.device.prop.keyCapabilities(k.index)
+}
+
+// Position of the key in the logical layout. Touch keys are reported in a row
+// below the layout, in the order they are in as mounted, as the row they are
+// in physically is not part of the rotated layout.
 func (k *key) Position() image.Point {
-	return k.pos
+	d := k.device
+	if k.index < d.prop.keys {
+		return d.rotation.Point(k.pos, d.prop.keyLayout)
+	}
+	var (
+		l = d.ButtonLayout()
+		i = k.index - d.prop.keys
+	)
+	if r := d.rotation & 3; r == benjamin.Rotate180 || r == benjamin.Rotate270 {
+		i = d.prop.touchKeys - 1 - i
+	}
+	return image.Pt(i*(l.X-1), l.Y)
 }
 
+// Size of the key image, keys without display report a zero size.
 func (k *key) Size() image.Point {
+	if k.image == nil {
+		return image.Point{}
+	}
 	return k.device.prop.keySize
 }
 
 func (k *key) SetImage(i image.Image) error {
+	if k.image == nil {
+		return fmt.Errorf("streamdeck: key %d: %w", k.index, benjamin.ErrNotSupported)
+	}
+
+	k.render(i)
+	return k.device.send(k.target(), k.frame, k.device.prop.keyImageFormat())
+}
+
+// render image i to the key image and the frame.
+func (k *key) render(i image.Image) {
 	// Fill our key image with the new image.
 	if i == nil {
 		i = blank
-	} else if i != k.image {
-		if o, ok := i.(*image.RGBA); ok && o.Rect.Eq(k.image.Rect) {
+	}
+	if i != k.image {
+		if o, ok := i.(*image.NRGBA); ok && o.Rect.Size().Eq(k.image.Rect.Size()) {
 			// Fast path, copy pixels.
-			copy(k.image.Pix, o.Pix)
+			imageutil.CopyNRGBA(k.image, image.Point{}, o, o.Rect)
 		} else {
 			// Interpolate image into key image.
-			ButtonImageInterpolator.Scale(k.image, k.image.Rect, i, i.Bounds(), draw.Src, nil)
-		}
-
-		// Apply transformations
-		if k.device.prop.keyImageTransform != nil {
-			k.device.prop.keyImageTransform.Transform(k.image)
+			k.device.encoding.buttonInterpolator().Scale(k.image, k.image.Rect, i, i.Bounds(), draw.Src, nil)
 		}
 	}
 
-	var (
-		b   []byte
-		err error
-	)
-	if k.device.prop.imageBytes != nil {
-		b, err = k.device.prop.imageBytes(k.image)
+	// Rotate to the mounting orientation, and apply transformations.
+	if k.device.rotation == benjamin.Rotate0 {
+		copy(k.frame.Pix, k.image.Pix)
 	} else {
-		b, err = convertJPEG(k.image)
+		rotateImage(k.frame, k.image, k.device.rotation)
 	}
-	if err != nil {
-		return err
+	if k.device.prop.keyImageTransform != nil {
+		k.device.prop.keyImageTransform.Transform(k.frame)
 	}
-	return k.device.SetButtonImage(k.index, b)
+}
+
+func (k *key) target() writeTarget {
+	return writeTarget{index: k.index}
 }
 
 // keyArea is a virtual screen that renders to all buttons
@@ -180,16 +226,9 @@ type keyArea struct {
 }
 
 func newKeyArea(device *Device) *keyArea {
-	var (
-		l = device.prop.keyLayout
-		s = device.prop.keySize
-		w = l.X * s.X
-		h = l.Y * s.Y
-	)
-	return &keyArea{
-		device: device,
-		canvas: image.NewNRGBA(image.Rect(0, 0, w, h)),
-	}
+	s := &keyArea{device: device}
+	s.canvas = image.NewNRGBA(image.Rectangle{Max: s.Size()})
+	return s
 }
 
 func (s *keyArea) Surface() benjamin.Surface {
@@ -200,54 +239,90 @@ func (s *keyArea) Index() int {
 	return -1
 }
 
+// Size of the area, this includes the gaps between the keys if the device
+// models the bezel.
 func (s *keyArea) Size() image.Point {
-	return s.canvas.Rect.Max
+	return s.device.ButtonAreaSize(s.device.bezel)
 }
 
 func (s *keyArea) SetImage(i image.Image) error {
+	s.fill(i)
+
+	var (
+		l      = s.device.ButtonLayout()
+		pitch  = s.pitch()
+		format = s.device.prop.keyImageFormat()
+		n      = l.X * l.Y
+		target = make([]writeTarget, n)
+		frames = make([]*frameBuffer, n)
+		errs   = make([]error, n)
+	)
+
+	// Render and encode concurrently, the frames are sent in order.
+	parallel(len(frames), func(n int) {
+		k := s.device.ButtonAt(image.Pt(n%l.X, n/l.X)).(*key)
+		s.renderKey(k, pitch)
+		target[n] = k.target()
+		frames[n], errs[n] = s.device.encode(target[n], k.frame, format)
+	})
+
+	for i, frame := range frames {
+		err := errs[i]
+		if err == nil {
+			err = s.device.deliver(target[i], frame)
+		}
+		if err != nil {
+			s.device.discard(target[i+1:], frames[i+1:])
+			return err
+		}
+	}
+	return nil
+}
+
+// fill the canvas with image i, scaled to fit.
+func (s *keyArea) fill(i image.Image) {
 	if i == nil {
 		i = blank
 	}
 
+	if size := s.Size(); !s.canvas.Rect.Size().Eq(size) {
+		s.canvas = image.NewNRGBA(image.Rectangle{Max: size})
+	}
+
 	if o, ok := i.(*image.NRGBA); ok && o.Rect.Eq(s.canvas.Rect) {
-		copy(s.canvas.Pix, o.Pix)
+		imageutil.CopyNRGBA(s.canvas, image.Point{}, o, o.Rect)
 	} else {
-		DisplayImageInterpolator.Scale(s.canvas, s.canvas.Rect, i, i.Bounds(), draw.Src, nil)
+		s.device.encoding.displayInterpolator().Scale(s.canvas, s.canvas.Rect, i, i.Bounds(), draw.Src, nil)
 	}
+}
 
-	for y := 0; y < s.device.prop.keyLayout.Y; y++ {
-		r := image.Rect(0, y*s.device.prop.keySize.Y, s.device.prop.keySize.X, (y+1)*s.device.prop.keySize.Y)
-		for x := 0; x < s.device.prop.keyLayout.X; x++ {
-			i := y*s.device.prop.keyLayout.X + x
-			k := s.device.key[i]
-			draw.Copy(k.image, image.Point{}, s.canvas, r, draw.Src, nil)
-			if err := k.SetImage(k.image); err != nil {
-				return err
-			}
-			r.Min.X += s.device.prop.keySize.X
-			r.Max.X += s.device.prop.keySize.X
-		}
+// pitch is the distance between the keys on the canvas.
+func (s *keyArea) pitch() image.Point {
+	pitch := s.device.prop.keySize
+	if s.device.bezel {
+		pitch = pitch.Add(s.device.rotation.Size(s.device.prop.keyGap))
 	}
-	return nil
+	return pitch
+}
+
+// renderKey renders the part of the canvas below key k.
+func (s *keyArea) renderKey(k *key, pitch image.Point) {
+	var (
+		p = k.Position()
+		o = image.Pt(p.X*pitch.X, p.Y*pitch.Y)
+	)
+	imageutil.CopyNRGBA(k.image, image.Point{}, s.canvas, image.Rectangle{Min: o, Max: o.Add(k.image.Rect.Size())})
+	k.render(k.image)
 }
 
-// displayArea is a virtual display that renders to all displays
+// displayArea is a virtual display that spans all displays, it is the bottom
+// layer of the display strip, see compositor.
 type displayArea struct {
 	device *Device
-	canvas *image.NRGBA
 }
 
 func newDisplayArea(device *Device) *displayArea {
-	var (
-		l = device.prop.displayLayout
-		s = device.prop.displaySize
-		w = l.X * s.X
-		h = l.Y * s.Y
-	)
-	return &displayArea{
-		device: device,
-		canvas: image.NewNRGBA(image.Rect(0, 0, w, h)),
-	}
+	return &displayArea{device: device}
 }
 
 func (s *displayArea) Surface() benjamin.Surface {
@@ -259,36 +334,29 @@ func (s *displayArea) Index() int {
 }
 
 func (s *displayArea) Size() image.Point {
-	return s.canvas.Rect.Max
+	return s.device.rotation.Size(s.device.stripSize())
 }
 
+// SetImage sets the image of the display area, images set on individual
+// displays are shown on top. Setting a nil image removes the image.
 func (s *displayArea) SetImage(i image.Image) error {
-	if s == nil {
-		panic("drawing to nil display area!")
-	}
-
-	if i == nil {
-		i = blank
-	}
-
-	// Fill our key image with the new image.
-	if o, ok := i.(*image.RGBA); ok && o.Rect.Eq(s.canvas.Rect) {
-		// Fast path, copy pixels.
-		copy(s.canvas.Pix, o.Pix)
-	} else {
-		// Interpolate image into key image.
-		DisplayImageInterpolator.Scale(s.canvas, s.canvas.Rect, i, i.Bounds(), draw.Src, nil)
-	}
-
-	b, err := convertJPEG(s.canvas)
-	if err != nil {
-		return err
-	}
+	return s.device.updateStrip(0, setLayer(i, s.device.encoding.displayInterpolator()))
+}
 
-	return s.device.SetDisplayImage(b)
+// SetImageRect updates the rectangle r of the area, only the changed region is
+// sent to devices that support partial updates.
+func (s *displayArea) SetImageRect(i image.Image, r image.Rectangle) error {
+	return s.device.updateStrip(0, func(l *layer) image.Rectangle {
+		r = r.Intersect(l.image.Rect)
+		draw.Draw(l.image, r, i, r.Min, draw.Src)
+		l.shown = true
+		return r
+	})
 }
 
 var (
-	_ benjamin.Screen = (*keyArea)(nil)
-	_ benjamin.Screen = (*displayArea)(nil)
+	_ benjamin.Screen          = (*keyArea)(nil)
+	_ benjamin.Screen          = (*displayArea)(nil)
+	_ benjamin.PartialDrawable = (*display)(nil)
+	_ benjamin.PartialDrawable = (*displayArea)(nil)
 )
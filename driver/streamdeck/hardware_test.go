@@ -0,0 +1,141 @@
+package streamdeck
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/karalabe/hid"
+
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+// nullTransport discards all writes.
+type nullTransport struct {
+	*transport.Memory
+}
+
+func (nullTransport) Write(p []byte) (int, error) { return len(p), nil }
+
+func benchmarkDevice(b *testing.B, prop Properties) *Device {
+	b.Helper()
+	d := NewWithTransport(hid.DeviceInfo{}, prop, nullTransport{transport.NewMemory()})
+	if err := d.Open(); err != nil {
+		b.Fatal(err)
+	}
+	return d
+}
+
+// benchmarkImages returns two different images, so frames are never skipped.
+func benchmarkImages(size image.Point) [2]*image.NRGBA {
+	return [2]*image.NRGBA{
+		testImage(size, color.NRGBA{R: 0xff, A: 0xff}),
+		testImage(size, color.NRGBA{B: 0xff, A: 0xff}),
+	}
+}
+
+func TestParallelEncoding(t *testing.T) {
+	defer func(n int) { EncodeConcurrency = n }(EncodeConcurrency)
+
+	writes := func(concurrency int) [][]byte {
+		EncodeConcurrency = concurrency
+		d, m := testDevice(t, XL)
+		defer d.Close()
+
+		// Every key gets a different image.
+		i := image.NewNRGBA(image.Rectangle{Max: d.ButtonArea().Size()})
+		for o := range i.Pix {
+			i.Pix[o] = uint8(o / 4 % 251)
+		}
+		if err := d.ButtonArea().SetImage(i); err != nil {
+			t.Fatal(err)
+		}
+		return m.Writes()
+	}
+
+	var (
+		want = writes(1)
+		got  = writes(8)
+	)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d packets, got %d", len(want), len(got))
+	}
+	for n := range want {
+		if !bytes.Equal(got[n], want[n]) {
+			t.Fatalf("packet %d differs from serial encoding", n)
+		}
+	}
+}
+
+func BenchmarkButtonSetImage(b *testing.B) {
+	for _, prop := range testModels {
+		if !prop.hasKeyImages() {
+			continue
+		}
+		b.Run(prop.Model, func(b *testing.B) {
+			d := benchmarkDevice(b, prop)
+			defer d.Close()
+
+			var (
+				images = benchmarkImages(prop.keySize)
+				button = d.Button(0)
+			)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if err := button.SetImage(images[n&1]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkButtonAreaSetImage(b *testing.B) {
+	for _, prop := range testModels {
+		if !prop.hasKeyImages() {
+			continue
+		}
+		b.Run(prop.Model, func(b *testing.B) {
+			d := benchmarkDevice(b, prop)
+			defer d.Close()
+
+			var (
+				area   = d.ButtonArea()
+				images = benchmarkImages(area.Size())
+			)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if err := area.SetImage(images[n&1]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDisplayAreaSetImage(b *testing.B) {
+	for _, prop := range testModels {
+		if prop.displays == 0 {
+			continue
+		}
+		b.Run(prop.Model, func(b *testing.B) {
+			d := benchmarkDevice(b, prop)
+			defer d.Close()
+
+			var (
+				area   = d.DisplayArea()
+				images = benchmarkImages(area.Size())
+			)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if err := area.SetImage(images[n&1]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package streamdeck
 import (
 	"image"
 
+	"github.com/tehmaze/benjamin"
 	"github.com/tehmaze/benjamin/driver"
 )
 
@@ -13,14 +14,15 @@ var MiniMK2 = Properties{
 	keys:                6,
 	keyLayout:           image.Point{3, 2},
 	keySize:             image.Point{80, 80},
+	keyGap:              image.Point{31, 31}, // physical pitch 111px minus key size 80px
 	keyDataOffset:       1,
 	keyTranslate:        translateRTL(5),
 	keyImageTransform:   transform(rotate180),
-	imageBytes:          convertBMP,
+	keyFormat:           benjamin.FormatBMP,
 	imagePageSize:       1024,
 	imagePageHeaderSize: 16,
 }
 
 func init() {
-	driver.RegisterUSB(VendorID, MiniMK2.ProductID, driverFor(MiniMK2))
+	driver.RegisterUSBWithOpener(VendorID, MiniMK2.ProductID, driverFor(MiniMK2))
 }
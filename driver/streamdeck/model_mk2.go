@@ -13,6 +13,7 @@ var MK2 = Properties{
 	keys:                15,
 	keyLayout:           image.Point{5, 3},
 	keySize:             image.Point{72, 72},
+	keyGap:              image.Point{25, 25}, // physical pitch 97px minus key size 72px
 	keyDataOffset:       3,
 	keyTranslate:        translateLTR(),
 	keyImageTransform:   transform(rotate180),
@@ -21,5 +22,5 @@ var MK2 = Properties{
 }
 
 func init() {
-	driver.RegisterUSB(VendorID, MK2.ProductID, driverFor(MK2))
+	driver.RegisterUSBWithOpener(VendorID, MK2.ProductID, driverFor(MK2))
 }
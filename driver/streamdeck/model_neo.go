@@ -0,0 +1,67 @@
+package streamdeck
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/tehmaze/benjamin/driver"
+)
+
+var Neo = Properties{
+	Model:               "Stream Deck Neo",
+	ProductID:           0x009a,
+	model:               neo,
+	displays:            1,
+	displaySize:         image.Point{248, 58},
+	displayLayout:       image.Pt(1, 1),
+	displayTransform:    transform(rotate180),
+	keys:                8,
+	keyLayout:           image.Point{4, 2},
+	keySize:             image.Point{96, 96},
+	keyGap:              image.Point{36, 36}, // physical pitch 132px minus key size 96px
+	touchKeys:           2,
+	keyDataOffset:       3,
+	keyTranslate:        translateLTR(),
+	keyImageTransform:   transform(rotate180),
+	imagePageSize:       1024,
+	imagePageHeaderSize: 8,
+}
+
+type neoModel struct {
+	*baseModel
+}
+
+func neo(device *Device) model {
+	return &neoModel{
+		baseModel: gen2(device).(*baseModel),
+	}
+}
+
+// SetDisplayImage sends the info bar image.
+func (m *neoModel) SetDisplayImage(_ image.Rectangle, imageBytes []byte) error {
+	err := m.writePages(imageBytes, m.prop.imagePageSize, m.prop.imagePageHeaderSize, neoInfoBarPageHeader)
+	if err != nil {
+		return fmt.Errorf("streamdeck: image transfer to info bar failed: %w", err)
+	}
+	return nil
+}
+
+func neoInfoBarPageHeader(dst []byte, pageIndex, dataSize int, isLast bool) []byte {
+	var last byte
+	if isLast {
+		last = 0x01
+	}
+	return append(dst,
+		0x02, 0x0b,
+		0x00,
+		last,
+		byte(dataSize),
+		byte(dataSize>>8),
+		byte(pageIndex),
+		byte(pageIndex>>8),
+	)
+}
+
+func init() {
+	driver.RegisterUSBWithOpener(VendorID, Neo.ProductID, driverFor(Neo))
+}
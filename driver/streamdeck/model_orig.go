@@ -3,6 +3,7 @@ package streamdeck
 import (
 	"image"
 
+	"github.com/tehmaze/benjamin"
 	"github.com/tehmaze/benjamin/driver"
 )
 
@@ -13,14 +14,15 @@ var Orig = Properties{
 	keys:                15,
 	keyLayout:           image.Point{5, 3},
 	keySize:             image.Point{72, 72},
+	keyGap:              image.Point{25, 25}, // physical pitch 97px minus key size 72px
 	keyDataOffset:       1,
 	keyTranslate:        translateRTL(5),
 	keyImageTransform:   transform(rotate180),
-	imageBytes:          convertBMP,
+	keyFormat:           benjamin.FormatBMP,
 	imagePageSize:       8191,
 	imagePageHeaderSize: 16,
 }
 
 func init() {
-	driver.RegisterUSB(VendorID, Orig.ProductID, driverFor(Orig))
+	driver.RegisterUSBWithOpener(VendorID, Orig.ProductID, driverFor(Orig))
 }
@@ -0,0 +1,40 @@
+package streamdeck
+
+import (
+	"image"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver"
+)
+
+var Pedal = Properties{
+	Model:               "Stream Deck Pedal",
+	ProductID:           0x0086,
+	model:               pedal,
+	keys:                3,
+	keyLayout:           image.Point{3, 1},
+	keyDataOffset:       3,
+	keyTranslate:        translateLTR(),
+	imagePageSize:       1024,
+	imagePageHeaderSize: 8,
+}
+
+// pedalReset does not send the key image reset, the pedal has no screens.
+func pedalReset(*Device) error {
+	return benjamin.ErrNotSupported
+}
+
+func pedalSetBrightness(*Device, float64) error {
+	return benjamin.ErrNotSupported
+}
+
+func pedal(device *Device) model {
+	m := gen2(device).(*baseModel)
+	m.reset = pedalReset
+	m.setBrightness = pedalSetBrightness
+	return m
+}
+
+func init() {
+	driver.RegisterUSBWithOpener(VendorID, Pedal.ProductID, driverFor(Pedal))
+}
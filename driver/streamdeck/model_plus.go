@@ -17,10 +17,13 @@ var Plus = Properties{
 	displays:            4,
 	displaySize:         image.Point{200, 100},
 	displayLayout:       image.Pt(4, 1),
+	displayTouch:        true,
+	displayRegions:      true,
 	encoders:            4,
 	keys:                8,
 	keyLayout:           image.Point{4, 2},
 	keySize:             image.Point{120, 120},
+	keyGap:              image.Point{48, 48}, // physical pitch 168px minus key size 120px
 	keyDataOffset:       3,
 	keyTranslate:        translateLTR(),
 	imagePageSize:       1024,
@@ -52,29 +55,34 @@ func (m *plusModel) Handle(p []byte, c chan<- benjamin.Event) {
 
 func (m *plusModel) handleDisplay(p []byte, c chan<- benjamin.Event) {
 	var (
-		x  = binary.LittleEndian.Uint16(p[5:])
-		y  = binary.LittleEndian.Uint16(p[7:])
-		at = image.Pt(int(x), int(y))
-	)
-	var (
-		index   = x / uint16(m.prop.displaySize.X)
+		size    = m.stripSize()
+		at      = stripPoint(p[5:], size)
+		index   = at.X / m.prop.displaySize.X
 		display = m.display[index]
 	)
+	at = m.rotation.Point(at, size)
 	switch p[3] {
 	case 0x01: // short press
 		c <- benjamin.NewDisplayPress(m, display, at)
 	case 0x02: // long press
 		c <- benjamin.NewDisplayLongPress(m, display, at)
 	case 0x03: // swipe
-		x = binary.LittleEndian.Uint16(p[9:])
-		y = binary.LittleEndian.Uint16(p[11:])
-		to := image.Pt(int(x), int(y))
+		to := m.rotation.Point(stripPoint(p[9:], size), size)
 		c <- benjamin.NewDisplaySwipe(m, display, at, to)
 	default:
 		log.Print("display: unknown command", p[3])
 	}
 }
 
+// stripPoint decodes a touch coordinate, the device reports coordinates on the
+// edge of the strip (such as x=800), these are clamped to the strip.
+func stripPoint(p []byte, size image.Point) image.Point {
+	return image.Pt(
+		clamp(int(binary.LittleEndian.Uint16(p[0:])), size.X-1),
+		clamp(int(binary.LittleEndian.Uint16(p[2:])), size.Y-1),
+	)
+}
+
 func (m *plusModel) handleEncoder(p []byte, c chan<- benjamin.Event) {
 	switch p[0] {
 	case 0x00: // press/release
@@ -107,5 +115,5 @@ func (m *plusModel) handleEncoder(p []byte, c chan<- benjamin.Event) {
 }
 
 func init() {
-	driver.RegisterUSB(VendorID, Plus.ProductID, driverFor(Plus))
+	driver.RegisterUSBWithOpener(VendorID, Plus.ProductID, driverFor(Plus))
 }
@@ -13,6 +13,7 @@ var XL = Properties{
 	keys:                32,
 	keyLayout:           image.Point{8, 4},
 	keySize:             image.Point{96, 96},
+	keyGap:              image.Point{30, 30}, // physical pitch 126px minus key size 96px
 	keyDataOffset:       3,
 	keyTranslate:        translateLTR(),
 	keyImageTransform:   transform(rotate180),
@@ -21,5 +22,5 @@ var XL = Properties{
 }
 
 func init() {
-	driver.RegisterUSB(VendorID, XL.ProductID, driverFor(XL))
+	driver.RegisterUSBWithOpener(VendorID, XL.ProductID, driverFor(XL))
 }
@@ -0,0 +1,238 @@
+package streamdeck
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"golang.org/x/image/draw"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+// Snapshot composes the last images set on the keys and displays in one image,
+// laid out like the physical device, in the orientation it is mounted with.
+// The keys are separated by the bezel gaps, the display strip is below the
+// keys. Keys and displays without an image are black.
+func (d *Device) Snapshot() (image.Image, error) {
+	var (
+		gap   = d.prop.keyGap
+		pitch = d.prop.keySize.Add(gap)
+		strip = d.stripSize()
+		keys  image.Point
+	)
+	if d.prop.hasKeyImages() {
+		l := d.prop.keyLayout
+		keys = image.Pt(l.X*pitch.X-gap.X, l.Y*pitch.Y-gap.Y)
+	}
+
+	size := keys
+	if strip.X > size.X {
+		size.X = strip.X
+	}
+	if strip.Y > 0 {
+		if size.Y > 0 {
+			size.Y += gap.Y
+		}
+		size.Y += strip.Y
+	}
+	if size.X == 0 || size.Y == 0 {
+		return nil, fmt.Errorf("streamdeck: %s has no screens: %w", d.prop.Model, benjamin.ErrNotSupported)
+	}
+
+	o := image.NewNRGBA(image.Rectangle{Max: d.rotation.Size(size)})
+	draw.Draw(o, o.Rect, image.Black, image.Point{}, draw.Src)
+
+	offset := image.Pt((size.X-keys.X)/2, 0)
+	for _, k := range d.key {
+		if k.image == nil {
+			continue
+		}
+		var (
+			p = offset.Add(image.Pt(k.pos.X*pitch.X, k.pos.Y*pitch.Y))
+			r = image.Rectangle{Min: p, Max: p.Add(d.prop.keySize)}
+		)
+		draw.Draw(o, rotateRect(r, size, d.rotation), k.image, image.Point{}, draw.Over)
+	}
+
+	d.stripMu.Lock()
+	defer d.stripMu.Unlock()
+	if d.strip != nil && d.strip.rotation == d.rotation {
+		var (
+			p = image.Pt((size.X-strip.X)/2, size.Y-strip.Y)
+			r = image.Rectangle{Min: p, Max: p.Add(strip)}
+		)
+		draw.Draw(o, rotateRect(r, size, d.rotation), d.strip.frame, image.Point{}, draw.Over)
+	}
+
+	return o, nil
+}
+
+// ReplayOutput updates the images kept by the device from output reports that
+// were written to a device of the same model, as returned by Output of a
+// transport.Replay. Snapshot then returns what that device showed after the
+// writes. Nothing is sent to the device.
+func (d *Device) ReplayOutput(output []transport.Record) error {
+	var (
+		keyData   = make(map[int][]byte)
+		stripData []byte
+		strip     *image.NRGBA // in physical orientation
+	)
+	if d.prop.displays > 0 {
+		strip = image.NewNRGBA(image.Rectangle{Max: d.stripSize()})
+		d.stripMu.Lock()
+		if d.strip != nil && d.strip.rotation == d.rotation {
+			rotateImage(strip, d.strip.frame, d.rotation)
+		}
+		d.stripMu.Unlock()
+	}
+
+	for n, r := range output {
+		p := []byte(r.Data)
+		if r.Op != transport.OpWrite || len(p) < 16 || p[0] != 0x02 {
+			continue
+		}
+
+		var (
+			index   = -1
+			page    int
+			last    bool
+			payload []byte
+			area    image.Rectangle
+		)
+		switch p[1] {
+		case 0x01: // gen1 key image, pages have no payload size
+			index, page, last, payload = int(p[5])-1, int(p[2])-1, p[4] != 0, p[gen1ImagePageHeaderSize:]
+		case 0x07: // gen2 key image
+			index, page, last, payload = int(p[2]), int(binary.LittleEndian.Uint16(p[6:])), p[3] != 0, p[8:]
+			payload = payload[:clamp(int(binary.LittleEndian.Uint16(p[4:])), len(payload))]
+		case 0x0b: // info bar
+			page, last, payload = int(binary.LittleEndian.Uint16(p[6:])), p[3] != 0, p[8:]
+			payload = payload[:clamp(int(binary.LittleEndian.Uint16(p[4:])), len(payload))]
+			if strip != nil {
+				area = strip.Rect
+			}
+		case 0x0c: // display region
+			var (
+				x = int(binary.LittleEndian.Uint16(p[2:]))
+				y = int(binary.LittleEndian.Uint16(p[4:]))
+				w = int(binary.LittleEndian.Uint16(p[6:]))
+				h = int(binary.LittleEndian.Uint16(p[8:]))
+			)
+			area = image.Rect(x, y, x+w, y+h)
+			page, last, payload = int(binary.LittleEndian.Uint16(p[11:])), p[10] != 0, p[16:]
+			payload = payload[:clamp(int(binary.LittleEndian.Uint16(p[13:])), len(payload))]
+		default:
+			continue
+		}
+
+		switch {
+		case index >= 0 && index < d.prop.keys && d.key[index].image != nil:
+			if page == 0 {
+				keyData[index] = keyData[index][:0]
+			}
+			keyData[index] = append(keyData[index], payload...)
+			if last {
+				if err := d.replayKey(d.key[index], keyData[index]); err != nil {
+					return fmt.Errorf("streamdeck: output %d: %w", n, err)
+				}
+			}
+		case !area.Empty() && strip != nil:
+			if page == 0 {
+				stripData = stripData[:0]
+			}
+			stripData = append(stripData, payload...)
+			if last {
+				if err := d.replayDisplay(strip, area, stripData); err != nil {
+					return fmt.Errorf("streamdeck: output %d: %w", n, err)
+				}
+			}
+		}
+	}
+
+	if strip != nil {
+		d.stripMu.Lock()
+		defer d.stripMu.Unlock()
+		if d.strip == nil || d.strip.rotation != d.rotation {
+			d.strip = newCompositor(d)
+		}
+		for n, l := range d.strip.layers {
+			l.shown = n == 0
+		}
+		rotateImage(d.strip.layers[0].image, strip, d.rotation.Inverse())
+		d.strip.compose(d.strip.frame.Rect)
+	}
+	return nil
+}
+
+// replayKey decodes the image data sent to key k into the key image.
+func (d *Device) replayKey(k *key, data []byte) error {
+	var err error
+	if d.prop.keyImageFormat() == benjamin.FormatBMP {
+		err = decodeBMP(k.frame, data)
+	} else {
+		err = decodeJPEG(k.frame, k.frame.Rect, data)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Undo the transformations, they are their own inverse.
+	if d.prop.keyImageTransform != nil {
+		d.prop.keyImageTransform.Transform(k.frame)
+	}
+	rotateImage(k.image, k.frame, d.rotation.Inverse())
+	return nil
+}
+
+// replayDisplay decodes the image data sent to area of the display strip.
+func (d *Device) replayDisplay(strip *image.NRGBA, area image.Rectangle, data []byte) error {
+	if d.prop.displayTransform == nil {
+		return decodeJPEG(strip, area, data)
+	}
+
+	// Transformed frames always contain the whole strip.
+	if err := decodeJPEG(strip, strip.Rect, data); err != nil {
+		return err
+	}
+	d.prop.displayTransform.Transform(strip)
+	return nil
+}
+
+// decodeBMP decodes image data as encoded by appendBMP into dst.
+func decodeBMP(dst *image.NRGBA, data []byte) error {
+	size := dst.Rect.Size()
+	if len(data) < len(bmpHeader)+size.X*size.Y*3 {
+		return fmt.Errorf("short BMP image of %d bytes", len(data))
+	}
+	data = data[len(bmpHeader):]
+	for y := 0; y < size.Y; y++ {
+		o := dst.PixOffset(dst.Rect.Min.X, dst.Rect.Min.Y+y)
+		for x := 0; x < size.X; x++ {
+			s := data[(y*size.X+x)*3:]
+			dst.Pix[o+0], dst.Pix[o+1], dst.Pix[o+2], dst.Pix[o+3] = s[2], s[1], s[0], 0xff
+			o += 4
+		}
+	}
+	return nil
+}
+
+// decodeJPEG decodes a JPEG image into the rectangle r of dst.
+func decodeJPEG(dst *image.NRGBA, r image.Rectangle, data []byte) error {
+	i, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	draw.Draw(dst, r, i, i.Bounds().Min, draw.Src)
+	return nil
+}
+
+func clamp(n, max int) int {
+	if n > max {
+		return max
+	}
+	return n
+}
@@ -0,0 +1,145 @@
+package streamdeck
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/karalabe/hid"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+// near returns whether the colors are the same, within JPEG precision.
+func near(a, b color.Color) bool {
+	var (
+		ar, ag, ab, _ = a.RGBA()
+		br, bg, bb, _ = b.RGBA()
+		diff          = func(a, b uint32) bool { return a>>8 > b>>8+8 || b>>8 > a>>8+8 }
+	)
+	return !diff(ar, br) && !diff(ag, bg) && !diff(ab, bb)
+}
+
+// drawTestScene sets red on key 0, green on key 1 and blue on the displays.
+func drawTestScene(t *testing.T, d *Device) {
+	t.Helper()
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	if err := d.Button(0).SetImage(testImage(d.prop.keySize, red)); err != nil {
+		t.Fatal(err)
+	}
+	green := color.NRGBA{G: 0xff, A: 0xff}
+	if err := d.Button(1).SetImage(testImage(d.prop.keySize, green)); err != nil {
+		t.Fatal(err)
+	}
+	if d.prop.displays > 0 {
+		blue := color.NRGBA{B: 0xff, A: 0xff}
+		if err := d.DisplayArea().SetImage(testImage(d.DisplayArea().Size(), blue)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	d, _ := testDevice(t, Plus)
+	defer d.Close()
+	drawTestScene(t, d)
+
+	i, err := d.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		pitch = Plus.keySize.Add(Plus.keyGap)
+		keys  = Plus.keyLayout.X*pitch.X - Plus.keyGap.X
+		x     = (Plus.displaySize.X*Plus.displays - keys) / 2
+		want  = image.Pt(Plus.displaySize.X*Plus.displays, Plus.keyLayout.Y*pitch.Y+Plus.displaySize.Y)
+	)
+	if size := i.Bounds().Size(); !size.Eq(want) {
+		t.Fatalf("expected snapshot of %s, got %s", want, size)
+	}
+	tests := []struct {
+		at   image.Point
+		want color.Color
+	}{
+		{image.Pt(x+10, 10), color.NRGBA{R: 0xff, A: 0xff}},
+		{image.Pt(x+pitch.X+10, 10), color.NRGBA{G: 0xff, A: 0xff}},
+		{image.Pt(x+Plus.keySize.X+Plus.keyGap.X/2, 10), color.Black},
+		{image.Pt(10, want.Y-10), color.NRGBA{B: 0xff, A: 0xff}},
+	}
+	for _, test := range tests {
+		if c := i.At(test.at.X, test.at.Y); !near(c, test.want) {
+			t.Errorf("at %s: expected %v, got %v", test.at, test.want, c)
+		}
+	}
+}
+
+func TestSnapshotRotation(t *testing.T) {
+	d, _ := testDevice(t, XL)
+	defer d.Close()
+	d.SetRotation(benjamin.Rotate90)
+	drawTestScene(t, d)
+
+	i, err := d.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := i.Bounds().Size(); size.X >= size.Y {
+		t.Errorf("expected rotated snapshot to be portrait, got %s", size)
+	}
+	// Button 0 is shown at its position on the rotated surface.
+	var (
+		p     = d.Button(0).(*key).Position()
+		pitch = d.rotation.Size(XL.keySize.Add(XL.keyGap))
+		at    = image.Pt(p.X*pitch.X+10, p.Y*pitch.Y+10)
+	)
+	if c := i.At(at.X, at.Y); !near(c, color.NRGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("expected button 0 at %s, got %v", at, c)
+	}
+}
+
+func TestReplayOutput(t *testing.T) {
+	for _, prop := range []Properties{Mini, XL, Plus, Neo} {
+		t.Run(prop.Model, func(t *testing.T) {
+			var (
+				info    = hid.DeviceInfo{VendorID: VendorID, ProductID: prop.ProductID}
+				capture bytes.Buffer
+				d       = NewWithTransport(info, prop, transport.NewCapture(transport.NewMemory(), info, &capture))
+			)
+			defer d.Close()
+			drawTestScene(t, d)
+
+			want, err := d.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			replay, err := transport.NewReplay(&capture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := NewWithTransport(replay.Info(), prop, replay)
+			if err = r.ReplayOutput(replay.Output()); err != nil {
+				t.Fatal(err)
+			}
+			got, err := r.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b := want.Bounds()
+			if !got.Bounds().Eq(b) {
+				t.Fatalf("expected snapshot bounds %s, got %s", b, got.Bounds())
+			}
+			for y := b.Min.Y + 4; y < b.Max.Y; y += 8 {
+				for x := b.Min.X + 4; x < b.Max.X; x += 8 {
+					if !near(got.At(x, y), want.At(x, y)) {
+						t.Fatalf("at %d,%d: expected %v, got %v", x, y, want.At(x, y), got.At(x, y))
+					}
+				}
+			}
+		})
+	}
+}
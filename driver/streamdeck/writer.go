@@ -0,0 +1,130 @@
+package streamdeck
+
+import (
+	"image"
+	"sync"
+)
+
+// writeTarget identifies what a frame is written to, a newer frame for the
+// same target supersedes a queued frame.
+type writeTarget struct {
+	display bool
+	index   int
+	rect    image.Rectangle // display region
+}
+
+// overlaps returns whether both targets write to the same pixels.
+func (t writeTarget) overlaps(o writeTarget) bool {
+	if t.display != o.display {
+		return false
+	}
+	if t.display {
+		return t.rect.Overlaps(o.rect)
+	}
+	return t.index == o.index
+}
+
+// writer sends frames in the background, in the order they were queued. If a
+// target already has a frame queued, the queued frame is dropped.
+type writer struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending map[writeTarget]func() error
+	order   []writeTarget
+	busy    bool
+	closed  bool
+	err     error
+	errors  chan<- error
+	done    chan struct{}
+}
+
+func newWriter(errors chan<- error) *writer {
+	w := &writer{
+		pending: make(map[writeTarget]func() error),
+		errors:  errors,
+		done:    make(chan struct{}),
+	}
+	w.cond = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+// queue a frame write for target.
+func (w *writer) queue(target writeTarget, write func() error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, queued := w.pending[target]; queued {
+		// Move to the back, so frames for overlapping targets queued in the
+		// meantime are not written over the newer frame.
+		for i, t := range w.order {
+			if t == target {
+				w.order = append(w.order[:i], w.order[i+1:]...)
+				break
+			}
+		}
+	}
+	w.order = append(w.order, target)
+	w.pending[target] = write
+	w.cond.Broadcast()
+}
+
+// flush waits for all queued frames to be written, and returns the first error
+// since the previous flush.
+func (w *writer) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.order) > 0 || w.busy {
+		w.cond.Wait()
+	}
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// close writes all queued frames and stops the writer.
+func (w *writer) close() error {
+	w.mu.Lock()
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+	<-w.done
+	return w.err
+}
+
+func (w *writer) run() {
+	defer close(w.done)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for {
+		for len(w.order) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.order) == 0 {
+			return
+		}
+
+		target := w.order[0]
+		w.order = w.order[1:]
+		write := w.pending[target]
+		delete(w.pending, target)
+
+		w.busy = true
+		w.mu.Unlock()
+		err := write()
+		w.mu.Lock()
+		w.busy = false
+
+		if err != nil {
+			if w.err == nil {
+				w.err = err
+			}
+			select {
+			case w.errors <- err:
+			default:
+				// Nobody is listening for events.
+			}
+		}
+		w.cond.Broadcast()
+	}
+}
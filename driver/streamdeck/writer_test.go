@@ -0,0 +1,88 @@
+package streamdeck
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/karalabe/hid"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+// slowTransport blocks writes until released.
+type slowTransport struct {
+	*transport.Memory
+	release chan struct{}
+	err     error
+}
+
+func (t *slowTransport) Write(p []byte) (int, error) {
+	<-t.release
+	if t.err != nil {
+		return 0, t.err
+	}
+	return t.Memory.Write(p)
+}
+
+func TestAsync(t *testing.T) {
+	var (
+		m = &slowTransport{Memory: transport.NewMemory(), release: make(chan struct{})}
+		d = NewWithTransport(hid.DeviceInfo{}, MK2, m)
+	)
+	if err := d.SetAsync(true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Key 0 is being written, the second frame is superseded by the third.
+	for _, c := range []color.Color{color.White, color.Black, color.White} {
+		if err := d.Button(0).SetImage(testImage(MK2.keySize, c)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(m.release)
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var frames int
+	for _, p := range m.Writes() {
+		if p[3] == 0x01 { // last page
+			frames++
+		}
+	}
+	if frames > 2 {
+		t.Errorf("expected superseded frame to be dropped, %d frames written", frames)
+	}
+}
+
+func TestAsyncError(t *testing.T) {
+	var (
+		errWrite = errors.New("write failed")
+		m        = &slowTransport{Memory: transport.NewMemory(), release: make(chan struct{}), err: errWrite}
+		d        = NewWithTransport(hid.DeviceInfo{}, MK2, m)
+	)
+	close(m.release)
+	d.SetAsync(true)
+	events, other := d.Events(), d.Events()
+
+	if err := d.Button(0).SetImage(testImage(MK2.keySize, color.White)); err != nil {
+		t.Fatalf("expected no error from SetImage, got %v", err)
+	}
+	if err := d.Flush(); !errors.Is(err, errWrite) {
+		t.Errorf("expected %v from Flush, got %v", errWrite, err)
+	}
+	// Every Events channel receives the error.
+	for _, c := range []<-chan benjamin.Event{events, other} {
+		event := <-c
+		if event.Type != benjamin.TypeError || !errors.Is(event.Data.(benjamin.Error).Error, errWrite) {
+			t.Errorf("expected write error event, got %s", event)
+		}
+	}
+	d.Close()
+	for range events {
+	}
+	for range other {
+	}
+}
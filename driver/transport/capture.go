@@ -0,0 +1,150 @@
+package transport
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/karalabe/hid"
+)
+
+// Op is a transport operation.
+type Op string
+
+// Recorded operations.
+const (
+	OpOpen              Op = "open"
+	OpRead              Op = "read"
+	OpWrite             Op = "write"
+	OpSendFeatureReport Op = "send-feature-report"
+	OpGetFeatureReport  Op = "get-feature-report"
+)
+
+// Record of a single transport operation, captures are stored as one JSON
+// encoded Record per line.
+//
+// Every record is tagged with the path of the device, so the traffic of multiple
+// devices can be written to one capture. The capture of a device starts with an
+// OpOpen record that holds the device info.
+type Record struct {
+	Time   time.Time       `json:"time"`
+	Op     Op              `json:"op"`
+	Device string          `json:"device,omitempty"`
+	Info   *hid.DeviceInfo `json:"info,omitempty"`
+	Data   Hex             `json:"data,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Hex encoded bytes.
+type Hex []byte
+
+func (h Hex) MarshalText() ([]byte, error) {
+	b := make([]byte, hex.EncodedLen(len(h)))
+	hex.Encode(b, h)
+	return b, nil
+}
+
+func (h *Hex) UnmarshalText(b []byte) error {
+	*h = make(Hex, hex.DecodedLen(len(b)))
+	_, err := hex.Decode(*h, b)
+	return err
+}
+
+// Capture wraps a Transport and records all traffic.
+type Capture struct {
+	Transport
+	path string
+	w    *captureWriter
+}
+
+// captureWriter writes records, it is shared by the captures of an Opener.
+type captureWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewCapture records all traffic over t, the transport of the device described
+// by info, to w.
+func NewCapture(t Transport, info hid.DeviceInfo, w io.Writer) *Capture {
+	return newCapture(t, info, &captureWriter{enc: json.NewEncoder(w)})
+}
+
+func newCapture(t Transport, info hid.DeviceInfo, w *captureWriter) *Capture {
+	c := &Capture{
+		Transport: t,
+		path:      info.Path,
+		w:         w,
+	}
+	c.write(Record{Time: time.Now(), Op: OpOpen, Info: &info})
+	return c
+}
+
+// CaptureOpener returns an Opener that captures all traffic of the opened
+// transports to w, the records of all devices are written to the same capture.
+func CaptureOpener(open Opener, w io.Writer) Opener {
+	cw := &captureWriter{enc: json.NewEncoder(w)}
+	return func(info hid.DeviceInfo) (Transport, error) {
+		t, err := open(info)
+		if err != nil {
+			return nil, err
+		}
+		return newCapture(t, info, cw), nil
+	}
+}
+
+// Err returns the first error encountered while writing the capture.
+func (c *Capture) Err() error {
+	c.w.mu.Lock()
+	defer c.w.mu.Unlock()
+	return c.w.err
+}
+
+func (c *Capture) Read(p []byte) (int, error) {
+	n, err := c.Transport.Read(p)
+	c.record(OpRead, p[:n], err)
+	return n, err
+}
+
+func (c *Capture) Write(p []byte) (int, error) {
+	n, err := c.Transport.Write(p)
+	c.record(OpWrite, p, err)
+	return n, err
+}
+
+func (c *Capture) SendFeatureReport(p []byte) (int, error) {
+	n, err := c.Transport.SendFeatureReport(p)
+	c.record(OpSendFeatureReport, p, err)
+	return n, err
+}
+
+func (c *Capture) GetFeatureReport(p []byte) (int, error) {
+	n, err := c.Transport.GetFeatureReport(p)
+	c.record(OpGetFeatureReport, p[:n], err)
+	return n, err
+}
+
+func (c *Capture) record(op Op, p []byte, err error) {
+	r := Record{
+		Time: time.Now(),
+		Op:   op,
+		Data: p,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	c.write(r)
+}
+
+func (c *Capture) write(r Record) {
+	r.Device = c.path
+	c.w.mu.Lock()
+	defer c.w.mu.Unlock()
+	if c.w.err == nil {
+		c.w.err = c.w.enc.Encode(r)
+	}
+}
+
+var _ Transport = (*Capture)(nil)
@@ -0,0 +1,104 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/karalabe/hid"
+)
+
+func TestCaptureReplay(t *testing.T) {
+	var (
+		m = NewMemory()
+		b bytes.Buffer
+		c = NewCapture(m, hid.DeviceInfo{Path: "a", ProductID: 0x0060, Serial: "AL123"}, &b)
+	)
+	m.Feed([]byte{0x01, 0x00, 0x01})
+	m.Feed([]byte{0x01, 0x00, 0x00})
+	m.SetFeatureReport([]byte{0x05, 0x0c, 0x31, 0x2e, 0x30})
+
+	p := make([]byte, 32)
+	for i := 0; i < 2; i++ {
+		if _, err := c.Read(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.Write([]byte{0x02, 0x07}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetFeatureReport([]byte{0x05, 0x00, 0x00, 0x00, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReplay(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := r.Info(); info.ProductID != 0x0060 || info.Serial != "AL123" {
+		t.Errorf("expected recorded device info, got %+v", info)
+	}
+	for _, want := range [][]byte{{0x01, 0x00, 0x01}, {0x01, 0x00, 0x00}} {
+		n, err := r.Read(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(p[:n], want) {
+			t.Errorf("expected read % x, got % x", want, p[:n])
+		}
+	}
+	if _, err = r.Read(p); err != io.EOF {
+		t.Errorf("expected EOF after last read, got %v", err)
+	}
+
+	if w := r.Writes(); len(w) != 1 || !bytes.Equal(w[0], []byte{0x02, 0x07}) {
+		t.Errorf("expected recorded write 02 07, got % x", w)
+	}
+
+	f := []byte{0x05, 0x00, 0x00, 0x00, 0x00}
+	if _, err = r.GetFeatureReport(f); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x05, 0x0c, 0x31, 0x2e, 0x30}; !bytes.Equal(f, want) {
+		t.Errorf("expected feature report % x, got % x", want, f)
+	}
+}
+
+func TestCaptureOpener(t *testing.T) {
+	var (
+		b    bytes.Buffer
+		open = CaptureOpener(func(hid.DeviceInfo) (Transport, error) {
+			return NewMemory(), nil
+		}, &b)
+	)
+	for _, path := range []string{"a", "b", "a"} {
+		c, err := open(hid.DeviceInfo{Path: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path == "b" {
+			_, _ = c.Write([]byte{0x02, 0x0b})
+		}
+	}
+
+	capture := b.String()
+	r, err := NewReplayOf(strings.NewReader(capture), "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := r.Writes(); len(w) != 1 || !bytes.Equal(w[0], []byte{0x02, 0x0b}) {
+		t.Errorf("expected recorded write 02 0b, got % x", w)
+	}
+	if r, err = NewReplay(strings.NewReader(capture)); err != nil {
+		t.Fatal(err)
+	} else if len(r.Writes()) != 0 || r.Info().Path != "a" {
+		t.Errorf("expected first device without writes, got %q with % x", r.Info().Path, r.Writes())
+	}
+	if _, err = NewReplayOf(strings.NewReader(capture), "c"); err == nil {
+		t.Error("expected error for device not in capture")
+	}
+}
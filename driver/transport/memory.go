@@ -0,0 +1,140 @@
+package transport
+
+import (
+	"io"
+	"sync"
+)
+
+// Memory is an in-memory Transport, it records all output and feature reports
+// and replies to reads with the input reports fed to it.
+type Memory struct {
+	mu       sync.Mutex
+	input    chan []byte
+	closed   chan struct{}
+	once     sync.Once
+	writes   [][]byte
+	sent     [][]byte
+	features map[byte][]byte
+}
+
+// NewMemory returns a new in-memory Transport.
+func NewMemory() *Memory {
+	return &Memory{
+		input:    make(chan []byte, 64),
+		closed:   make(chan struct{}),
+		features: make(map[byte][]byte),
+	}
+}
+
+// Feed an input report, which will be returned by a subsequent Read.
+func (m *Memory) Feed(p []byte) {
+	select {
+	case m.input <- append([]byte(nil), p...):
+	case <-m.closed:
+	}
+}
+
+// SetFeatureReport sets the reply to GetFeatureReport for the report ID in
+// the first byte of p.
+func (m *Memory) SetFeatureReport(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	m.mu.Lock()
+	m.features[p[0]] = append([]byte(nil), p...)
+	m.mu.Unlock()
+}
+
+// Writes returns the output reports written so far.
+func (m *Memory) Writes() [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]byte(nil), m.writes...)
+}
+
+// FeatureReports returns the feature reports sent so far.
+func (m *Memory) FeatureReports() [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]byte(nil), m.sent...)
+}
+
+// Reset forgets all recorded output and feature reports.
+func (m *Memory) Reset() {
+	m.mu.Lock()
+	m.writes = nil
+	m.sent = nil
+	m.mu.Unlock()
+}
+
+// Read blocks until an input report is fed, or until the transport is closed,
+// in which case io.EOF is returned once all fed reports are read.
+func (m *Memory) Read(p []byte) (int, error) {
+	select {
+	case b := <-m.input:
+		return copy(p, b), nil
+	default:
+	}
+	select {
+	case b := <-m.input:
+		return copy(p, b), nil
+	case <-m.closed:
+		return 0, io.EOF
+	}
+}
+
+func (m *Memory) Write(p []byte) (int, error) {
+	if m.isClosed() {
+		return 0, io.ErrClosedPipe
+	}
+	m.mu.Lock()
+	m.writes = append(m.writes, append([]byte(nil), p...))
+	m.mu.Unlock()
+	return len(p), nil
+}
+
+func (m *Memory) SendFeatureReport(p []byte) (int, error) {
+	if m.isClosed() {
+		return 0, io.ErrClosedPipe
+	}
+	m.mu.Lock()
+	m.sent = append(m.sent, append([]byte(nil), p...))
+	m.mu.Unlock()
+	return len(p), nil
+}
+
+// GetFeatureReport copies the report set with SetFeatureReport, if no report
+// was set, the report is zero filled.
+func (m *Memory) GetFeatureReport(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if m.isClosed() {
+		return 0, io.ErrClosedPipe
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if b, ok := m.features[p[0]]; ok {
+		return copy(p, b), nil
+	}
+	for i := 1; i < len(p); i++ {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func (m *Memory) Close() error {
+	m.once.Do(func() { close(m.closed) })
+	return nil
+}
+
+func (m *Memory) isClosed() bool {
+	select {
+	case <-m.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+var _ Transport = (*Memory)(nil)
@@ -0,0 +1,183 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/karalabe/hid"
+)
+
+// Replay is a Transport that plays back a capture. Reads return the recorded
+// input reports in order, feature report requests are answered with the
+// recorded replies and everything written to it is discarded. The recorded
+// output reports are available from Writes and Output.
+type Replay struct {
+	// Realtime replays the input reports with their recorded timing.
+	Realtime bool
+
+	info     hid.DeviceInfo
+	mu       sync.Mutex
+	reads    []Record
+	writes   [][]byte
+	output   []Record
+	features map[byte][]Record
+	last     time.Time
+	closed   bool
+}
+
+// NewReplay loads a capture as written by Capture. If the capture contains the
+// traffic of multiple devices, the first device is replayed.
+func NewReplay(r io.Reader) (*Replay, error) {
+	return NewReplayOf(r, "")
+}
+
+// NewReplayOf loads the traffic of the device with path from a capture as
+// written by Capture, an empty path selects the first device in the capture.
+func NewReplayOf(r io.Reader, path string) (*Replay, error) {
+	p := &Replay{
+		features: make(map[byte][]Record),
+	}
+	var found bool
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(s.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("transport: capture line %d: %w", line, err)
+		}
+		if record.Op == OpOpen && record.Info != nil && !found && (path == "" || record.Info.Path == path) {
+			p.info, found = *record.Info, true
+		}
+		if record.Device != "" && (!found || record.Device != p.info.Path) {
+			continue
+		}
+		switch record.Op {
+		case OpRead:
+			p.reads = append(p.reads, record)
+		case OpWrite:
+			if record.Error == "" {
+				p.writes = append(p.writes, record.Data)
+				p.output = append(p.output, record)
+			}
+		case OpSendFeatureReport:
+			if record.Error == "" {
+				p.output = append(p.output, record)
+			}
+		case OpGetFeatureReport:
+			if len(record.Data) > 0 {
+				p.features[record.Data[0]] = append(p.features[record.Data[0]], record)
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if path != "" && !found {
+		return nil, fmt.Errorf("transport: no device %q in capture", path)
+	}
+	return p, nil
+}
+
+// Info returns the info of the recorded device, it is empty for captures that
+// don't record the device.
+func (p *Replay) Info() hid.DeviceInfo {
+	return p.info
+}
+
+// Read returns the next recorded input report, io.EOF is returned once all
+// reports are consumed.
+func (p *Replay) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	if p.closed || len(p.reads) == 0 {
+		p.mu.Unlock()
+		return 0, io.EOF
+	}
+	r := p.reads[0]
+	p.reads = p.reads[1:]
+	var wait time.Duration
+	if p.Realtime && !p.last.IsZero() {
+		wait = r.Time.Sub(p.last)
+	}
+	p.last = r.Time
+	p.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	if r.Error != "" {
+		return 0, errors.New(r.Error)
+	}
+	return copy(b, r.Data), nil
+}
+
+// Writes returns the recorded output reports that were written successfully.
+func (p *Replay) Writes() [][]byte {
+	return p.writes
+}
+
+// Output returns the recorded output reports and sent feature reports that were
+// written successfully, in the order they were written.
+func (p *Replay) Output() []Record {
+	return p.output
+}
+
+func (p *Replay) Write(b []byte) (int, error) {
+	return len(b), p.check()
+}
+
+func (p *Replay) SendFeatureReport(b []byte) (int, error) {
+	return len(b), p.check()
+}
+
+// GetFeatureReport returns the next recorded reply for the report ID in the
+// first byte of b, the last reply is repeated once all are consumed.
+func (p *Replay) GetFeatureReport(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	rs := p.features[b[0]]
+	if len(rs) == 0 {
+		return 0, fmt.Errorf("transport: no recorded feature report %#02x", b[0])
+	}
+	r := rs[0]
+	if len(rs) > 1 {
+		p.features[b[0]] = rs[1:]
+	}
+	if r.Error != "" {
+		return 0, errors.New(r.Error)
+	}
+	return copy(b, r.Data), nil
+}
+
+func (p *Replay) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *Replay) check() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return io.ErrClosedPipe
+	}
+	return nil
+}
+
+var _ Transport = (*Replay)(nil)
@@ -0,0 +1,54 @@
+// Package transport contains the HID transports used by the device drivers.
+package transport
+
+import (
+	"io"
+
+	"github.com/karalabe/hid"
+)
+
+// Transport is a HID connection to a device.
+type Transport interface {
+	// Read an input report from the device.
+	Read([]byte) (int, error)
+
+	// Write an output report to the device.
+	Write([]byte) (int, error)
+
+	// SendFeatureReport sends a feature report, the first byte is the report ID.
+	SendFeatureReport([]byte) (int, error)
+
+	// GetFeatureReport retrieves a feature report, the first byte of the buffer
+	// must contain the report ID.
+	GetFeatureReport([]byte) (int, error)
+
+	io.Closer
+}
+
+// Opener opens a Transport for a HID device. Drivers use Open unless they are
+// constructed with another Opener, such as one returned by CaptureOpener.
+type Opener func(hid.DeviceInfo) (Transport, error)
+
+// DefaultOpener is used by Open. Prefer passing an Opener to the drivers over
+// replacing it, it applies to every device in the process.
+var DefaultOpener Opener = HID
+
+// Open a Transport using the DefaultOpener.
+func Open(info hid.DeviceInfo) (Transport, error) {
+	return DefaultOpener(info)
+}
+
+// HID opens a Transport using the host HID library.
+func HID(info hid.DeviceInfo) (Transport, error) {
+	dev, err := info.Open()
+	if err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
+var (
+	_ Transport = (*hid.Device)(nil)
+	_ Opener    = HID
+	_ Opener    = Open
+)
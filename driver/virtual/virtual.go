@@ -0,0 +1,450 @@
+// Package virtual contains a device that combines multiple physical devices
+// into a single surface.
+package virtual
+
+import (
+	"image"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+
+	"github.com/tehmaze/benjamin"
+)
+
+// ImageInterpolator is used to scale images set on the combined button area.
+var ImageInterpolator draw.Interpolator = draw.CatmullRom
+
+// Placement of a device on the combined surface.
+type Placement struct {
+	Device benjamin.Device
+
+	// Offset of the top left button of the device, in cols x rows.
+	Offset image.Point
+}
+
+// Device combines multiple devices into one. The button layout of the combined
+// device spans all placements, buttons, displays and encoders are numbered in
+// the order of the placements.
+//
+// The combined button area holds the button area of every device at its own
+// size, including bezels and rotation. Placement offsets are scaled by the
+// largest button pitch of all devices, so devices with different button sizes
+// don't overlap.
+//
+// Devices may be rotated after they are combined, the layout and the button
+// positions follow the layout of the devices.
+type Device struct {
+	placement  []Placement
+	button     []*button
+	display    []*display
+	encoder    []*encoder
+	buttonArea *buttonArea
+	peripheral map[benjamin.Peripheral]benjamin.Peripheral
+	done       chan struct{}
+	once       sync.Once
+}
+
+// New combines the devices in placement, devices should not be opened yet.
+func New(placement ...Placement) *Device {
+	d := &Device{
+		placement:  placement,
+		peripheral: make(map[benjamin.Peripheral]benjamin.Peripheral),
+		done:       make(chan struct{}),
+	}
+	for _, p := range placement {
+		device := p.Device
+		for i, l := 0, device.Buttons(); i < l; i++ {
+			if b := device.Button(i); b != nil {
+				w := &button{
+					Button: b,
+					device: d,
+					index:  len(d.button),
+					offset: p.Offset,
+				}
+				d.button = append(d.button, w)
+				d.peripheral[b] = w
+			}
+		}
+		displays := make(map[benjamin.Display]*display)
+		for i, l := 0, device.Displays(); i < l; i++ {
+			if s := device.Display(i); s != nil {
+				w := &display{
+					Display: s,
+					device:  d,
+					index:   len(d.display),
+				}
+				d.display = append(d.display, w)
+				d.peripheral[s] = w
+				displays[s] = w
+			}
+		}
+		for i, l := 0, device.Encoders(); i < l; i++ {
+			if e := device.Encoder(i); e != nil {
+				w := &encoder{
+					Encoder: e,
+					device:  d,
+					index:   len(d.encoder),
+				}
+				if s := e.Display(); s != nil {
+					w.display = displays[s]
+				}
+				d.encoder = append(d.encoder, w)
+				d.peripheral[e] = w
+			}
+		}
+	}
+	if !d.ButtonSize().Eq(image.Point{}) {
+		d.buttonArea = &buttonArea{
+			device: d,
+			canvas: new(image.NRGBA),
+		}
+		for _, p := range placement {
+			if s := p.Device.ButtonArea(); s != nil {
+				d.peripheral[s] = d.buttonArea
+			}
+		}
+	}
+	return d
+}
+
+// Placements returns the devices and their placement.
+func (d *Device) Placements() []Placement {
+	return append([]Placement(nil), d.placement...)
+}
+
+func (d *Device) Manufacturer() string { return d.join(benjamin.Device.Manufacturer) }
+func (d *Device) Product() string      { return d.join(benjamin.Device.Product) }
+func (d *Device) Serial() string       { return d.join(benjamin.Device.Serial) }
+
+func (d *Device) join(f func(benjamin.Device) string) string {
+	s := make([]string, len(d.placement))
+	for i, p := range d.placement {
+		s[i] = f(p.Device)
+	}
+	return strings.Join(s, "+")
+}
+
+// Open all devices, if any device fails to open, the devices opened so far
+// are closed again.
+func (d *Device) Open() error {
+	for i, p := range d.placement {
+		if err := p.Device.Open(); err != nil {
+			for _, p := range d.placement[:i] {
+				_ = p.Device.Close()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Close all devices, this stops forwarding events.
+func (d *Device) Close() error {
+	d.once.Do(func() { close(d.done) })
+	return d.each(benjamin.Device.Close)
+}
+
+func (d *Device) Reset() error {
+	return d.each(benjamin.Device.Reset)
+}
+
+func (d *Device) Clear() error {
+	return d.each(benjamin.Device.Clear)
+}
+
+func (d *Device) SetBrightness(v float64) error {
+	return d.each(func(device benjamin.Device) error {
+		return device.SetBrightness(v)
+	})
+}
+
+// each calls f for all devices, and returns the first error.
+func (d *Device) each(f func(benjamin.Device) error) error {
+	var err error
+	for _, p := range d.placement {
+		if ferr := f(p.Device); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
+// Events returns the merged events of all devices, the channel is closed when
+// the event channels of all devices are closed. Events are no longer forwarded
+// after Close, so the device channels are drained if the channel is not.
+func (d *Device) Events() <-chan benjamin.Event {
+	var (
+		c    = make(chan benjamin.Event, 16)
+		wait sync.WaitGroup
+	)
+	for _, p := range d.placement {
+		wait.Add(1)
+		go func(events <-chan benjamin.Event) {
+			defer wait.Done()
+			for event := range events {
+				select {
+				case c <- event.Translate(d, d.translate):
+				case <-d.done:
+				}
+			}
+		}(p.Device.Events())
+	}
+	go func() {
+		wait.Wait()
+		close(c)
+	}()
+	return c
+}
+
+func (d *Device) translate(p benjamin.Peripheral) benjamin.Peripheral {
+	if w, ok := d.peripheral[p]; ok {
+		return w
+	}
+	return p
+}
+
+func (d *Device) Capabilities() benjamin.Capabilities {
+	var c benjamin.Capabilities
+	for i, p := range d.placement {
+		o := benjamin.CapabilitiesOf(p.Device)
+		c.Buttons = append(c.Buttons, o.Buttons...)
+		c.Displays = append(c.Displays, o.Displays...)
+		c.Encoders = append(c.Encoders, o.Encoders...)
+		if i == 0 {
+			c.Brightness = o.Brightness
+			c.StandbyImage = o.StandbyImage
+		} else {
+			c.StandbyImage = c.StandbyImage && o.StandbyImage
+		}
+	}
+	return c
+}
+
+func (d *Device) Display(index int) benjamin.Display {
+	if index < 0 || index >= len(d.display) {
+		return nil
+	}
+	return d.display[index]
+}
+
+func (d *Device) Displays() int {
+	return len(d.display)
+}
+
+// DisplayArea is not combined, it returns nil.
+func (d *Device) DisplayArea() benjamin.Screen {
+	return nil
+}
+
+func (d *Device) Encoder(index int) benjamin.Encoder {
+	if index < 0 || index >= len(d.encoder) {
+		return nil
+	}
+	return d.encoder[index]
+}
+
+func (d *Device) Encoders() int {
+	return len(d.encoder)
+}
+
+func (d *Device) Button(index int) benjamin.Button {
+	if index < 0 || index >= len(d.button) {
+		return nil
+	}
+	return d.button[index]
+}
+
+// ButtonAt returns the button at p, if devices overlap, the device placed first
+// wins. Returns nil if there is no device at p.
+func (d *Device) ButtonAt(p image.Point) benjamin.Button {
+	for _, placement := range d.placement {
+		if b := placement.Device.ButtonAt(p.Sub(placement.Offset)); b != nil {
+			if w, ok := d.peripheral[b].(*button); ok {
+				return w
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Device) Buttons() int {
+	return len(d.button)
+}
+
+// ButtonLayout spans the button layouts of all devices, at their offsets.
+func (d *Device) ButtonLayout() image.Point {
+	var layout image.Point
+	for _, p := range d.placement {
+		l := p.Offset.Add(p.Device.ButtonLayout())
+		layout.X, layout.Y = max(layout.X, l.X), max(layout.Y, l.Y)
+	}
+	return layout
+}
+
+// ButtonSize is the size of the buttons of the first device with buttons.
+func (d *Device) ButtonSize() image.Point {
+	if len(d.button) == 0 {
+		return image.Point{}
+	}
+	return d.button[0].Size()
+}
+
+func (d *Device) ButtonArea() benjamin.Screen {
+	if d.buttonArea == nil {
+		return nil
+	}
+	return d.buttonArea
+}
+
+type button struct {
+	benjamin.Button
+	device *Device
+	index  int
+	offset image.Point // of the placement
+}
+
+func (b *button) Capabilities() benjamin.PeripheralCapabilities {
+	return benjamin.PeripheralCapabilitiesOf(b.Button)
+}
+
+func (b *button) Surface() benjamin.Surface { return b.device }
+func (b *button) Index() int                { return b.index }
+func (b *button) Position() image.Point     { return b.Button.Position().Add(b.offset) }
+
+type display struct {
+	benjamin.Display
+	device *Device
+	index  int
+}
+
+func (s *display) Capabilities() benjamin.PeripheralCapabilities {
+	return benjamin.PeripheralCapabilitiesOf(s.Display)
+}
+
+func (s *display) Surface() benjamin.Surface { return s.device }
+func (s *display) Index() int                { return s.index }
+
+type encoder struct {
+	benjamin.Encoder
+	device  *Device
+	index   int
+	display *display
+}
+
+func (e *encoder) Capabilities() benjamin.PeripheralCapabilities {
+	return benjamin.PeripheralCapabilitiesOf(e.Encoder)
+}
+
+func (e *encoder) Surface() benjamin.Surface { return e.device }
+func (e *encoder) Index() int                { return e.index }
+
+func (e *encoder) Display() benjamin.Display {
+	if e.display == nil {
+		return nil
+	}
+	return e.display
+}
+
+// buttonArea spans the buttons of all devices, images are sliced across the
+// button areas of the devices according to their placement.
+type buttonArea struct {
+	mu     sync.Mutex
+	device *Device
+	canvas *image.NRGBA
+}
+
+// member is the part of the combined button area shown by a device.
+type member struct {
+	area   benjamin.Screen
+	offset image.Point // in cols x rows
+	rect   image.Rectangle
+}
+
+// members returns the button areas of the devices and the combined size. The
+// sizes of the button areas can change, for example when a device is rotated.
+func (s *buttonArea) members() ([]member, image.Point) {
+	var (
+		members []member
+		pitch   image.Point
+		size    image.Point
+	)
+	for _, p := range s.device.placement {
+		area, layout := p.Device.ButtonArea(), p.Device.ButtonLayout()
+		if area == nil || layout.X == 0 || layout.Y == 0 {
+			continue
+		}
+		a := area.Size()
+		pitch.X = max(pitch.X, (a.X+layout.X-1)/layout.X)
+		pitch.Y = max(pitch.Y, (a.Y+layout.Y-1)/layout.Y)
+		members = append(members, member{area: area, offset: p.Offset, rect: image.Rectangle{Max: a}})
+	}
+	for n := range members {
+		m := &members[n]
+		m.rect = m.rect.Add(image.Pt(m.offset.X*pitch.X, m.offset.Y*pitch.Y))
+		size.X, size.Y = max(size.X, m.rect.Max.X), max(size.Y, m.rect.Max.Y)
+	}
+	return members, size
+}
+
+func (s *buttonArea) Surface() benjamin.Surface { return s.device }
+func (s *buttonArea) Index() int                { return -1 }
+
+func (s *buttonArea) Size() image.Point {
+	_, size := s.members()
+	return size
+}
+
+func (s *buttonArea) SetImage(i image.Image) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members, size := s.members()
+	if !s.canvas.Rect.Size().Eq(size) {
+		s.canvas = image.NewNRGBA(image.Rectangle{Max: size})
+	}
+	switch {
+	case i == nil:
+		draw.Draw(s.canvas, s.canvas.Rect, image.Black, image.Point{}, draw.Src)
+	case isUniform(i) || i.Bounds().Size().Eq(s.canvas.Rect.Size()):
+		draw.Draw(s.canvas, s.canvas.Rect, i, i.Bounds().Min, draw.Src)
+	default:
+		ImageInterpolator.Scale(s.canvas, s.canvas.Rect, i, i.Bounds(), draw.Src, nil)
+	}
+
+	var err error
+	for _, m := range members {
+		// Pass a view with its origin at 0,0, so devices can copy the pixels
+		// instead of scaling the image.
+		view := &image.NRGBA{
+			Pix:    s.canvas.Pix[s.canvas.PixOffset(m.rect.Min.X, m.rect.Min.Y):],
+			Stride: s.canvas.Stride,
+			Rect:   image.Rectangle{Max: m.rect.Size()},
+		}
+		if serr := m.area.SetImage(view); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func isUniform(i image.Image) bool {
+	_, ok := i.(*image.Uniform)
+	return ok
+}
+
+var (
+	_ benjamin.Device             = (*Device)(nil)
+	_ benjamin.CapabilityReporter = (*Device)(nil)
+	_ benjamin.Button             = (*button)(nil)
+	_ benjamin.Display            = (*display)(nil)
+	_ benjamin.Encoder            = (*encoder)(nil)
+	_ benjamin.Screen             = (*buttonArea)(nil)
+)
@@ -0,0 +1,203 @@
+package virtual
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+	"time"
+
+	"github.com/karalabe/hid"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/mock"
+	"github.com/tehmaze/benjamin/driver/streamdeck"
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+func TestDevice(t *testing.T) {
+	var (
+		left  = mock.NewWithLayout(mock.LayoutOf(streamdeck.XL))
+		right = mock.NewWithLayout(mock.LayoutOf(streamdeck.XL))
+		d     = New(
+			Placement{Device: left},
+			Placement{Device: right, Offset: image.Pt(8, 0)},
+		)
+	)
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	if l := d.ButtonLayout(); !l.Eq(image.Pt(16, 4)) {
+		t.Errorf("expected 16x4 layout, got %s", l)
+	}
+	if n := d.Buttons(); n != 64 {
+		t.Errorf("expected 64 buttons, got %d", n)
+	}
+
+	b := d.ButtonAt(image.Pt(9, 1))
+	if b == nil {
+		t.Fatal("expected button at (9,1)")
+	}
+	if p := b.Position(); !p.Eq(image.Pt(9, 1)) {
+		t.Errorf("expected button at (9,1), got %s", p)
+	}
+	if b.Surface() != d {
+		t.Error("expected button on combined surface")
+	}
+
+	// Left half white, right half black.
+	var (
+		size = d.ButtonArea().Size()
+		i    = image.NewNRGBA(image.Rectangle{Max: size})
+	)
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X/2; x++ {
+			i.Set(x, y, color.White)
+		}
+	}
+	if err := d.ButtonArea().SetImage(i); err != nil {
+		t.Fatal(err)
+	}
+	if c := left.Button(7).(*mock.Button).Image().NRGBAAt(0, 0); c.R != 0xff {
+		t.Errorf("expected white key on the left device, got %v", c)
+	}
+	if c := right.Button(0).(*mock.Button).Image().NRGBAAt(0, 0); c.R != 0 {
+		t.Errorf("expected black key on the right device, got %v", c)
+	}
+
+	if err := d.SetBrightness(0.5); err != nil {
+		t.Fatal(err)
+	}
+	if v := right.Brightness(); len(v) != 1 || v[0] != 0.5 {
+		t.Errorf("expected brightness on the right device, got %v", v)
+	}
+
+	events := d.Events()
+	right.Press(9)
+	event := <-events
+	if event.Type != benjamin.TypeButtonPress {
+		t.Fatalf("expected button press, got %s", event)
+	}
+	if event.Peripheral != b {
+		t.Errorf("expected press on %s, got %s", b.Position(), event.Peripheral.(benjamin.Button).Position())
+	}
+	if event.Data.Device() != d {
+		t.Error("expected event on combined device")
+	}
+
+	d.Close()
+	for range events {
+	}
+}
+
+func TestMixedButtonAreas(t *testing.T) {
+	var (
+		mini = streamdeck.NewWithTransport(hid.DeviceInfo{}, streamdeck.Mini, transport.NewMemory())
+		mk2  = streamdeck.NewWithTransport(hid.DeviceInfo{}, streamdeck.MK2, transport.NewMemory())
+		d    = New(
+			Placement{Device: mini},
+			Placement{Device: mk2, Offset: image.Pt(3, 0)},
+		)
+	)
+	mk2.SetBezel(true)
+
+	// The Mini has a pitch of 80x80, the MK.2 with bezel 92x89 (460x266 for 5x3
+	// keys), so the MK.2 starts at 3*92.
+	if s := d.ButtonArea().Size(); !s.Eq(image.Pt(3*92+460, 266)) {
+		t.Fatalf("expected 736x266 button area, got %s", s)
+	}
+
+	var (
+		i     = image.NewNRGBA(image.Rectangle{Max: d.ButtonArea().Size()})
+		red   = color.NRGBA{R: 0xff, A: 0xff}
+		white = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	)
+	draw.Draw(i, image.Rect(160, 0, 240, 80), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(i, image.Rect(276, 0, 276+72, 72), image.NewUniform(white), image.Point{}, draw.Src)
+	if err := d.ButtonArea().SetImage(i); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := mini.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := s.At(2*111+40, 40); c != red {
+		t.Errorf("expected Mini key 2 to be red, got %v", c)
+	}
+	if s, err = mk2.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		p    image.Point
+		want color.Color
+	}{
+		{image.Pt(0, 0), white},
+		{image.Pt(71, 71), white},
+		{image.Pt(97, 0), color.NRGBA{A: 0xff}},
+	} {
+		if c := s.At(test.p.X, test.p.Y); c != test.want {
+			t.Errorf("MK.2 at %s: expected %v, got %v", test.p, test.want, c)
+		}
+	}
+}
+
+func TestEventsAfterClose(t *testing.T) {
+	var (
+		m = mock.NewWithLayout(mock.DefaultLayout)
+		d = New(Placement{Device: m})
+	)
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nobody reads the events, the forwarder blocks on a full channel.
+	events := d.Events()
+	for i := 0; i < 32; i++ {
+		m.Press(0)
+	}
+	for len(events) < cap(events) {
+		time.Sleep(time.Millisecond)
+	}
+
+	d.Close()
+	time.Sleep(10 * time.Millisecond)
+	var n int
+	for range events {
+		n++
+	}
+	if n > cap(events) {
+		t.Errorf("expected events to be dropped after Close, got %d events", n)
+	}
+}
+
+func TestRotatedMember(t *testing.T) {
+	var (
+		mk2  = streamdeck.NewWithTransport(hid.DeviceInfo{}, streamdeck.MK2, transport.NewMemory())
+		mini = streamdeck.NewWithTransport(hid.DeviceInfo{}, streamdeck.Mini, transport.NewMemory())
+		d    = New(
+			Placement{Device: mk2},
+			Placement{Device: mini, Offset: image.Pt(5, 0)},
+		)
+	)
+	if l := d.ButtonLayout(); !l.Eq(image.Pt(8, 3)) {
+		t.Errorf("expected 8x3 layout, got %s", l)
+	}
+
+	// The MK.2 is 3x5 when rotated, the physical bottom left key is at the
+	// top left.
+	mk2.SetRotation(benjamin.Rotate90)
+	if l := d.ButtonLayout(); !l.Eq(image.Pt(8, 5)) {
+		t.Errorf("expected 8x5 layout, got %s", l)
+	}
+	if p := d.Button(10).Position(); !p.Eq(image.Pt(0, 0)) {
+		t.Errorf("expected button 10 at (0,0), got %s", p)
+	}
+	if b := d.ButtonAt(image.Pt(0, 0)); b == nil || b.Index() != 10 {
+		t.Error("expected button 10 at (0,0)")
+	}
+	if p := d.Button(15).Position(); !p.Eq(image.Pt(5, 0)) {
+		t.Errorf("expected the first Mini button at (5,0), got %s", p)
+	}
+}
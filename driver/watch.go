@@ -0,0 +1,269 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/karalabe/hid"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver/transport"
+)
+
+// WatchInterval is the interval at which Watch scans for devices.
+var WatchInterval = time.Second
+
+// ID is the identity of a device, it is stable for as long as the device is
+// connected to the same port, or for devices that report a serial number, as
+// long as the device is connected.
+type ID struct {
+	VendorID  uint16
+	ProductID uint16
+	Serial    string
+	Path      string
+}
+
+// IDOf returns the identity of a device.
+func IDOf(device benjamin.Device) ID {
+	if usb, ok := device.(benjamin.USBDevice); ok {
+		return idOf(usb.DeviceInfo())
+	}
+	return ID{
+		Serial: device.Serial(),
+		Path:   device.Manufacturer() + "/" + device.Product(),
+	}
+}
+
+func idOf(info hid.DeviceInfo) ID {
+	return ID{
+		VendorID:  info.VendorID,
+		ProductID: info.ProductID,
+		Serial:    info.Serial,
+		Path:      info.Path,
+	}
+}
+
+// Key used to match devices, this is the serial number if the device has one
+// and the path otherwise.
+func (id ID) Key() string {
+	if id.Serial != "" {
+		return fmt.Sprintf("%04x:%04x/%s", id.VendorID, id.ProductID, id.Serial)
+	}
+	return fmt.Sprintf("%04x:%04x@%s", id.VendorID, id.ProductID, id.Path)
+}
+
+func (id ID) String() string {
+	return id.Key()
+}
+
+// HotplugType is the type of a HotplugEvent.
+type HotplugType int
+
+// Hotplug event types.
+const (
+	Attach HotplugType = iota
+	Detach
+)
+
+func (t HotplugType) String() string {
+	switch t {
+	case Attach:
+		return "attach"
+	case Detach:
+		return "detach"
+	default:
+		return "invalid"
+	}
+}
+
+// HotplugEvent notifies about a device being attached or detached.
+type HotplugEvent struct {
+	Type HotplugType
+	ID   ID
+
+	// Device that was attached or detached. Attached devices are not opened.
+	// Every Watch call constructs its own device, so its receiver may open it.
+	Device benjamin.Device
+}
+
+func (event HotplugEvent) String() string {
+	return fmt.Sprintf("%s %s", event.Type, event.ID)
+}
+
+// Watch for devices being attached or detached, until the context is done.
+// All devices present when Watch is called are reported as attached.
+//
+// All Watch calls share a single scan loop, that runs while there is at least
+// one caller watching. The devices are constructed per Watch call, the device
+// of a detach event is the device of the matching attach event.
+func Watch(ctx context.Context) <-chan HotplugEvent {
+	return WatchWith(ctx, transport.Open)
+}
+
+// WatchWith is like Watch, but USB devices are opened with the supplied Opener.
+func WatchWith(ctx context.Context, open transport.Opener) <-chan HotplugEvent {
+	c := make(chan HotplugEvent, 16)
+
+	s := watchers.subscribe()
+	go func(c chan<- HotplugEvent) {
+		defer close(c)
+		defer watchers.unsubscribe(s)
+
+		devices := make(map[string]benjamin.Device)
+		for {
+			for _, change := range s.pop() {
+				event := HotplugEvent{Type: change.typ, ID: change.id}
+				key := change.id.Key()
+				if change.typ == Attach {
+					event.Device = change.device(open)
+					devices[key] = event.Device
+				} else {
+					event.Device = devices[key]
+					delete(devices, key)
+				}
+				if !send(ctx, c, event) {
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.notify:
+			}
+		}
+	}(c)
+
+	return c
+}
+
+func send(ctx context.Context, c chan<- HotplugEvent, event HotplugEvent) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case c <- event:
+		return true
+	}
+}
+
+// watchers is the scan loop shared by all Watch calls.
+var watchers = watcher{
+	subscriptions: make(map[*subscription]bool),
+	known:         make(map[string]candidate),
+}
+
+// watcher scans for devices and publishes hotplug events to its subscriptions.
+type watcher struct {
+	mu            sync.Mutex
+	subscriptions map[*subscription]bool
+	known         map[string]candidate
+	running       bool
+}
+
+// subscribe starts the scan loop if it is not running, the subscription starts
+// with attach events for the known devices.
+func (w *watcher) subscribe() *subscription {
+	s := &subscription{notify: make(chan struct{}, 1)}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, candidate := range w.known {
+		s.queue = append(s.queue, change{Attach, candidate})
+	}
+	w.subscriptions[s] = true
+	if !w.running {
+		w.running = true
+		go w.run(WatchInterval)
+	}
+	return s
+}
+
+func (w *watcher) unsubscribe(s *subscription) {
+	w.mu.Lock()
+	delete(w.subscriptions, s)
+	w.mu.Unlock()
+}
+
+// run scans every interval until there are no subscriptions left.
+func (w *watcher) run(interval time.Duration) {
+	for {
+		w.scan()
+		time.Sleep(interval)
+
+		w.mu.Lock()
+		if len(w.subscriptions) == 0 {
+			w.running = false
+			w.known = make(map[string]candidate)
+			w.mu.Unlock()
+			return
+		}
+		w.mu.Unlock()
+	}
+}
+
+func (w *watcher) scan() {
+	candidates := enumerate()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		key := candidate.id.Key()
+		seen[key] = true
+		if _, ok := w.known[key]; ok {
+			continue
+		}
+		w.known[key] = candidate
+		w.publish(change{Attach, candidate})
+	}
+	for key, candidate := range w.known {
+		if seen[key] {
+			continue
+		}
+		delete(w.known, key)
+		w.publish(change{Detach, candidate})
+	}
+}
+
+// publish a change to all subscriptions, the caller must hold the lock.
+func (w *watcher) publish(c change) {
+	for s := range w.subscriptions {
+		s.push(c)
+	}
+}
+
+// change is an attached or detached candidate, the subscriptions construct
+// their own devices from it.
+type change struct {
+	typ HotplugType
+	candidate
+}
+
+// subscription queues the events for a Watch call, so a slow receiver does not
+// hold up the scans.
+type subscription struct {
+	mu     sync.Mutex
+	queue  []change
+	notify chan struct{}
+}
+
+func (s *subscription) push(c change) {
+	s.mu.Lock()
+	s.queue = append(s.queue, c)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscription) pop() []change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queue := s.queue
+	s.queue = nil
+	return queue
+}
@@ -0,0 +1,75 @@
+package driver_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/benjamin"
+	"github.com/tehmaze/benjamin/driver"
+	"github.com/tehmaze/benjamin/driver/mock"
+)
+
+type hotplugDevice struct {
+	*mock.Mock
+}
+
+func (hotplugDevice) Serial() string { return "hotplug" }
+
+func TestWatch(t *testing.T) {
+	var (
+		present     atomic.Bool
+		constructed atomic.Int32
+	)
+	driver.RegisterForTest(t, present.Load, func() benjamin.Device {
+		constructed.Add(1)
+		return hotplugDevice{mock.NewWithLayout(mock.DefaultLayout)}
+	})
+
+	driver.SetWatchIntervalForTest(t, time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var (
+		c    = driver.Watch(ctx)
+		wait = func(want driver.HotplugType) driver.HotplugEvent {
+			t.Helper()
+			for {
+				select {
+				case event := <-c:
+					if event.ID.Serial == "hotplug" {
+						if event.Type != want {
+							t.Fatalf("expected %s, got %s", want, event.Type)
+						}
+						return event
+					}
+				case <-ctx.Done():
+					t.Fatalf("timeout waiting for %s", want)
+				}
+			}
+		}
+	)
+
+	present.Store(true)
+	attached := wait(driver.Attach)
+
+	// Watches share the scans, but every Watch gets its own device.
+	shared := driver.Watch(ctx)
+	for event := range shared {
+		if event.ID.Serial == "hotplug" {
+			if event.Type != driver.Attach || event.Device == attached.Device {
+				t.Errorf("expected a new device, got %s", event)
+			}
+			break
+		}
+	}
+	time.Sleep(10 * driver.WatchInterval)
+	if n := constructed.Load(); n != 2 {
+		t.Errorf("expected the driver to be constructed once per Watch, got %d", n)
+	}
+	present.Store(false)
+	if detached := wait(driver.Detach); detached.Device != attached.Device {
+		t.Error("expected detach to carry the attached device")
+	}
+}
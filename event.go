@@ -22,6 +22,67 @@ func (e Event) String() string {
 	return fmt.Sprintf("type=%s data=%s", e.Type, e.Data)
 }
 
+// Translate returns a copy of the event that appears to originate from device,
+// with all peripherals replaced by the result of f.
+func (e Event) Translate(device Device, f func(Peripheral) Peripheral) Event {
+	if e.Peripheral != nil {
+		e.Peripheral = f(e.Peripheral)
+	}
+	switch data := e.Data.(type) {
+	case Error:
+		data.On = device
+		e.Data = data
+	case DeviceAttach:
+		data.On = device
+		e.Data = data
+	case DeviceDetach:
+		data.On = device
+		e.Data = data
+	case DeviceReconnect:
+		data.On = device
+		e.Data = data
+	case DeviceSleep:
+		data.On = device
+		e.Data = data
+	case DeviceWake:
+		data.On = device
+		e.Data = data
+	case ButtonPress:
+		data.On = device
+		data.Button, _ = f(data.Button).(Button)
+		e.Data = data
+	case ButtonRelease:
+		data.On = device
+		data.Button, _ = f(data.Button).(Button)
+		e.Data = data
+	case DisplayPress:
+		data.On = device
+		data.Display, _ = f(data.Display).(Display)
+		e.Data = data
+	case DisplayLongPress:
+		data.On = device
+		data.Display, _ = f(data.Display).(Display)
+		e.Data = data
+	case DisplaySwipe:
+		data.On = device
+		data.Display, _ = f(data.Display).(Display)
+		e.Data = data
+	case EncoderChange:
+		data.On = device
+		data.Encoder, _ = f(data.Encoder).(Encoder)
+		e.Data = data
+	case EncoderPress:
+		data.On = device
+		data.Encoder, _ = f(data.Encoder).(Encoder)
+		e.Data = data
+	case EncoderRelease:
+		data.On = device
+		data.Encoder, _ = f(data.Encoder).(Encoder)
+		e.Data = data
+	}
+	return e
+}
+
 type EventType int
 
 const (
@@ -34,6 +95,11 @@ const (
 	TypeEncoderChange
 	TypeEncoderPress
 	TypeEncoderRelease
+	TypeDeviceAttach
+	TypeDeviceDetach
+	TypeDeviceReconnect
+	TypeDeviceSleep
+	TypeDeviceWake
 	TypeMax
 )
 
@@ -47,6 +113,11 @@ var eventTypeName = map[EventType]string{
 	TypeEncoderChange:    "EncoderChange",
 	TypeEncoderPress:     "EncoderPress",
 	TypeEncoderRelease:   "EncoderRelease",
+	TypeDeviceAttach:     "DeviceAttach",
+	TypeDeviceDetach:     "DeviceDetach",
+	TypeDeviceReconnect:  "DeviceReconnect",
+	TypeDeviceSleep:      "DeviceSleep",
+	TypeDeviceWake:       "DeviceWake",
 }
 
 func (t EventType) String() string {
@@ -111,6 +182,108 @@ func (event Error) String() string {
 	return fmt.Sprintf("error:%s", event.Error)
 }
 
+// DeviceAttach is emitted when a device becomes available.
+type DeviceAttach struct {
+	BaseEvent
+}
+
+func NewDeviceAttach(device Device) Event {
+	return Event{
+		Type: TypeDeviceAttach,
+		Data: DeviceAttach{
+			BaseEvent: makeBaseEvent(device),
+		},
+	}
+}
+
+func (event DeviceAttach) String() string {
+	return "device attach"
+}
+
+// DeviceDetach is emitted when a device goes away, Error is the reason for the
+// detach, if known.
+type DeviceDetach struct {
+	BaseEvent
+	Error error
+}
+
+func NewDeviceDetach(device Device, err error) Event {
+	return Event{
+		Type: TypeDeviceDetach,
+		Data: DeviceDetach{
+			BaseEvent: makeBaseEvent(device),
+			Error:     err,
+		},
+	}
+}
+
+func (event DeviceDetach) String() string {
+	if event.Error == nil {
+		return "device detach"
+	}
+	return fmt.Sprintf("device detach: %s", event.Error)
+}
+
+// DeviceReconnect is emitted when a detached device is available again, After
+// is the time the device was unavailable.
+type DeviceReconnect struct {
+	BaseEvent
+	After time.Duration
+}
+
+func NewDeviceReconnect(device Device, after time.Duration) Event {
+	return Event{
+		Type: TypeDeviceReconnect,
+		Data: DeviceReconnect{
+			BaseEvent: makeBaseEvent(device),
+			After:     after,
+		},
+	}
+}
+
+func (event DeviceReconnect) String() string {
+	return fmt.Sprintf("device reconnect: after=%s", event.After)
+}
+
+// DeviceSleep is emitted when a device goes to sleep.
+type DeviceSleep struct {
+	BaseEvent
+}
+
+func NewDeviceSleep(device Device) Event {
+	return Event{
+		Type: TypeDeviceSleep,
+		Data: DeviceSleep{
+			BaseEvent: makeBaseEvent(device),
+		},
+	}
+}
+
+func (event DeviceSleep) String() string {
+	return "device sleep"
+}
+
+// DeviceWake is emitted when a device wakes up, After is the time the device
+// was sleeping.
+type DeviceWake struct {
+	BaseEvent
+	After time.Duration
+}
+
+func NewDeviceWake(device Device, after time.Duration) Event {
+	return Event{
+		Type: TypeDeviceWake,
+		Data: DeviceWake{
+			BaseEvent: makeBaseEvent(device),
+			After:     after,
+		},
+	}
+}
+
+func (event DeviceWake) String() string {
+	return fmt.Sprintf("device wake: after=%s", event.After)
+}
+
 type ButtonPress struct {
 	BaseEvent
 	Button
@@ -1,11 +1,16 @@
 package benjamin
 
 import (
+	"errors"
 	"image"
 
 	"github.com/karalabe/hid"
 )
 
+// ErrNotSupported is returned for operations not supported by a device or
+// peripheral.
+var ErrNotSupported = errors.New("benjamin: not supported")
+
 type Device interface {
 	Manufacturer() string
 	Product() string
@@ -27,6 +32,16 @@ type USBDevice interface {
 	DeviceInfo() hid.DeviceInfo
 }
 
+// FirmwareDevice can report information stored in the device firmware, the
+// device has to be opened.
+type FirmwareDevice interface {
+	// Firmware version.
+	Firmware() (string, error)
+
+	// DeviceSerial is the serial number as reported by the device.
+	DeviceSerial() (string, error)
+}
+
 type Surface interface {
 	Display(int) Display
 	Displays() int
@@ -52,11 +67,36 @@ type Peripheral interface {
 	Index() int
 }
 
+// Drawable is a peripheral that can display images. Peripherals without a
+// screen, such as pedals, report a zero Size and return ErrNotSupported from
+// SetImage.
 type Drawable interface {
 	Size() image.Point
 	SetImage(image.Image) error
 }
 
+// HasScreen returns whether the Drawable has a screen.
+func HasScreen(d Drawable) bool {
+	return d != nil && !d.Size().Eq(image.Point{})
+}
+
+// PartialDrawable is a Drawable that can update part of its image.
+type PartialDrawable interface {
+	Drawable
+
+	// SetImageRect updates the rectangle r with the pixels of i at the same
+	// coordinates, i is not scaled. Typically i is the complete image, of
+	// which only r has changed.
+	SetImageRect(i image.Image, r image.Rectangle) error
+}
+
+// Snapshotter is a Surface that keeps the images it shows.
+type Snapshotter interface {
+	// Snapshot composes the last images set on the buttons and displays in one
+	// image, laid out like the physical device.
+	Snapshot() (image.Image, error)
+}
+
 type Display interface {
 	Peripheral
 	Drawable
@@ -0,0 +1,91 @@
+package imageutil
+
+import "image"
+
+// CopyNRGBA copies the rectangle r of src to dst at dp, clipped to both images.
+func CopyNRGBA(dst *image.NRGBA, dp image.Point, src *image.NRGBA, r image.Rectangle) {
+	r = r.Intersect(src.Rect)
+	dr := r.Add(dp.Sub(r.Min)).Intersect(dst.Rect)
+	if dr.Empty() {
+		return
+	}
+	var (
+		sp = r.Min.Add(dr.Min.Sub(dp))
+		w  = dr.Dx() * 4
+	)
+	for y := 0; y < dr.Dy(); y++ {
+		var (
+			d = dst.PixOffset(dr.Min.X, dr.Min.Y+y)
+			s = src.PixOffset(sp.X, sp.Y+y)
+		)
+		copy(dst.Pix[d:d+w], src.Pix[s:s+w])
+	}
+}
+
+// ToRGBA converts src to premultiplied alpha in dst, dst is resized to the
+// bounds of src if needed.
+func ToRGBA(dst *image.RGBA, src *image.NRGBA) {
+	var (
+		size = src.Rect.Size()
+		w    = size.X * 4
+	)
+	if n := w * size.Y; cap(dst.Pix) < n {
+		dst.Pix = make([]uint8, n)
+	} else {
+		dst.Pix = dst.Pix[:n]
+	}
+	dst.Stride = w
+	dst.Rect = src.Rect
+
+	for y := 0; y < size.Y; y++ {
+		var (
+			s = src.Pix[src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+y):][:w:w]
+			d = dst.Pix[y*w:][:w:w]
+		)
+		for x := 0; x < w; x += 4 {
+			switch a := s[x+3]; a {
+			case 0xff:
+				copy(d[x:x+4], s[x:x+4])
+			case 0x00:
+				d[x+0], d[x+1], d[x+2], d[x+3] = 0, 0, 0, 0
+			default:
+				d[x+0] = premultiply(s[x+0], a)
+				d[x+1] = premultiply(s[x+1], a)
+				d[x+2] = premultiply(s[x+2], a)
+				d[x+3] = a
+			}
+		}
+	}
+}
+
+// ToBGRInto converts src to dst, which must have the same size. Transparent
+// pixels are blended with black.
+func ToBGRInto(dst *BGR, src *image.NRGBA) {
+	size := src.Rect.Size()
+	for y := 0; y < size.Y; y++ {
+		var (
+			s = src.Pix[src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+y):][: size.X*4 : size.X*4]
+			d = dst.Pix[dst.PixOffset(dst.Rect.Min.X, dst.Rect.Min.Y+y):][: size.X*3 : size.X*3]
+		)
+		for x := 0; x < size.X; x++ {
+			var (
+				p = s[x*4 : x*4+4 : x*4+4]
+				q = d[x*3 : x*3+3 : x*3+3]
+			)
+			if a := p[3]; a == 0xff {
+				q[0], q[1], q[2] = p[2], p[1], p[0]
+			} else {
+				q[0], q[1], q[2] = premultiply(p[2], a), premultiply(p[1], a), premultiply(p[0], a)
+			}
+		}
+	}
+}
+
+// premultiply c with alpha a, like color.NRGBA.RGBA does.
+func premultiply(c, a uint8) uint8 {
+	v := uint32(c)
+	v |= v << 8
+	v *= uint32(a) | uint32(a)<<8
+	v /= 0xffff
+	return uint8(v >> 8)
+}
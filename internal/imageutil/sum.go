@@ -0,0 +1,32 @@
+package imageutil
+
+import (
+	"hash/maphash"
+	"image"
+)
+
+var seed = maphash.MakeSeed()
+
+// Sum returns a hash of the pixel data, used to detect unchanged frames. The
+// hash is only stable within the running process.
+func Sum(pix []byte) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	h.Write(pix)
+	return h.Sum64()
+}
+
+// SumNRGBA returns a hash of the pixels of i, like Sum, but only includes the
+// pixels within the bounds of i.
+func SumNRGBA(i *image.NRGBA) uint64 {
+	var (
+		h = new(maphash.Hash)
+		w = i.Rect.Dx() * 4
+	)
+	h.SetSeed(seed)
+	for y := i.Rect.Min.Y; y < i.Rect.Max.Y; y++ {
+		o := i.PixOffset(i.Rect.Min.X, y)
+		h.Write(i.Pix[o : o+w])
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,69 @@
+package benjamin
+
+import "image"
+
+// Rotation is the clockwise rotation a Surface is mounted with.
+type Rotation int
+
+// Rotations.
+const (
+	Rotate0 Rotation = iota
+	Rotate90
+	Rotate180
+	Rotate270
+)
+
+var rotationName = map[Rotation]string{
+	Rotate0:   "0°",
+	Rotate90:  "90°",
+	Rotate180: "180°",
+	Rotate270: "270°",
+}
+
+func (r Rotation) String() string {
+	if s, ok := rotationName[r]; ok {
+		return s
+	}
+	return "invalid"
+}
+
+// Inverse returns the rotation that undoes r.
+func (r Rotation) Inverse() Rotation {
+	return (4 - r&3) & 3
+}
+
+// Size returns the logical size of a physical area of size.
+func (r Rotation) Size(size image.Point) image.Point {
+	switch r & 3 {
+	case Rotate90, Rotate270:
+		return image.Pt(size.Y, size.X)
+	default:
+		return size
+	}
+}
+
+// Point maps point p in a physical area of size to logical coordinates. Use the
+// Inverse rotation with the logical size to map logical to physical coordinates.
+func (r Rotation) Point(p, size image.Point) image.Point {
+	switch r & 3 {
+	case Rotate90:
+		return image.Pt(size.Y-1-p.Y, p.X)
+	case Rotate180:
+		return image.Pt(size.X-1-p.X, size.Y-1-p.Y)
+	case Rotate270:
+		return image.Pt(p.Y, size.X-1-p.X)
+	default:
+		return p
+	}
+}
+
+// Rotatable is a Surface that can be mounted rotated. Positions, layouts, event
+// coordinates and images of a rotated Surface are all in logical coordinates,
+// where up is the top of the Surface as mounted.
+type Rotatable interface {
+	Rotation() Rotation
+
+	// SetRotation sets the clockwise rotation the Surface is mounted with,
+	// this should be done before drawing.
+	SetRotation(Rotation)
+}
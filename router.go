@@ -22,6 +22,9 @@ func (r Router) Handle(e Event) {
 	}
 }
 
+// On registers a handler for events of type t on peripheral p. Device events,
+// such as TypeDeviceDetach, have no peripheral and are routed to handlers
+// registered with a nil peripheral.
 func (r Router) On(p Peripheral, t EventType, h EventHandler) {
 	r[t] = append(r[t], Route{
 		Peripheral:   p,